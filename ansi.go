@@ -0,0 +1,153 @@
+package dcron
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences (only SGR sequences are converted, others are removed)
+var ansiRe = regexp.MustCompile(`\x1b\[([0-9;]*)([A-Za-z])|\x1b[()][A-Za-z0-9]|\x1b[^\[()]`)
+
+var ansiColors = []string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// ansi256Color returns color of 256 colors palette
+func ansi256Color(n int) string {
+	switch {
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	case n < 256:
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+	return ""
+}
+
+// ansiStyle text attributes set by SGR sequences
+type ansiStyle struct {
+	bold, italic, underline bool
+	fg, bg                  string
+}
+
+func (s ansiStyle) css() string {
+	var css []string
+	if s.bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.italic {
+		css = append(css, "font-style:italic")
+	}
+	if s.underline {
+		css = append(css, "text-decoration:underline")
+	}
+	if s.fg != "" {
+		css = append(css, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		css = append(css, "background-color:"+s.bg)
+	}
+	return strings.Join(css, ";")
+}
+
+// extendedColor parses 5;n or 2;r;g;b parameters of 38/48 codes,
+// returns the color and number of consumed parameters
+func extendedColor(params []int) (string, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return ansi256Color(params[1]), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", params[1]&255, params[2]&255, params[3]&255), 4
+	}
+	return "", len(params)
+}
+
+func (s *ansiStyle) apply(codes string) {
+	var params []int
+	for _, code := range strings.Split(codes, ";") {
+		n, _ := strconv.Atoi(code)
+		params = append(params, n)
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*s = ansiStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 22:
+			s.bold = false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p >= 30 && p <= 37:
+			s.fg = ansiColors[p-30]
+		case p >= 90 && p <= 97:
+			s.fg = ansiColors[p-90+8]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = ansiColors[p-40]
+		case p >= 100 && p <= 107:
+			s.bg = ansiColors[p-100+8]
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			color, n := extendedColor(params[i+1:])
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+			i += n
+		}
+	}
+}
+
+// ansiConverter converts text with ANSI escape sequences to HTML, style
+// is preserved between converted texts
+type ansiConverter struct {
+	style ansiStyle
+}
+
+func (c *ansiConverter) span(text string) string {
+	if text == "" {
+		return ""
+	}
+	if css := c.style.css(); css != "" {
+		return fmt.Sprintf(`<span style="%s">%s</span>`, css, html.EscapeString(text))
+	}
+	return html.EscapeString(text)
+}
+
+// Convert returns HTML of the text
+func (c *ansiConverter) Convert(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range ansiRe.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(c.span(text[last:m[0]]))
+		last = m[1]
+		if m[4] >= 0 && text[m[4]:m[5]] == "m" {
+			c.style.apply(text[m[2]:m[3]])
+		}
+	}
+	b.WriteString(c.span(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package dcron
+
+import "testing"
+
+func TestAnsiConverter(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		expected string
+	}{
+		{"plain text", []string{"a < b"}, "a &lt; b"},
+		{"color", []string{"\x1b[31mError\x1b[0m done"}, `<span style="color:#cd0000">Error</span> done`},
+		{"bold and background", []string{"\x1b[1;42mOK\x1b[22m!"}, `<span style="font-weight:bold;background-color:#00cd00">OK</span><span style="background-color:#00cd00">!</span>`},
+		{"256 colors", []string{"\x1b[38;5;196mred"}, `<span style="color:#ff0000">red</span>`},
+		{"true color", []string{"\x1b[38;2;1;2;3mx"}, `<span style="color:#010203">x</span>`},
+		{"other sequences removed", []string{"\x1b[2Kline\x1b(B"}, "line"},
+		{"style kept between texts", []string{"\x1b[33mwarn ", "more\x1b[39m"}, `<span style="color:#cdcd00">warn </span><span style="color:#cdcd00">more</span>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &ansiConverter{}
+			html := ""
+			for _, text := range test.texts {
+				html += c.Convert(text)
+			}
+			if html != test.expected {
+				t.Errorf("html %q, expected %q", html, test.expected)
+			}
+		})
+	}
+}
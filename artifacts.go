@@ -0,0 +1,182 @@
+package dcron
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// artifactInfo file collected from task container
+type artifactInfo struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// GetArtifactsPath location of directory with artifacts of the task run
+func (m *TaskManager) GetArtifactsPath(task string, id int) string {
+	return filepath.Join(m.LogsRoot, fmt.Sprintf("%s.%d", task, id))
+}
+
+// cleanArtifactPath returns relative slash separated path, or empty string
+// for paths outside of artifacts directory
+func cleanArtifactPath(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// extractArtifacts extracts regular files and directories of tar archive into
+// dir, files exceeding remaining size (if not negative) are not extracted.
+// Returns size of extracted files.
+func extractArtifacts(r io.Reader, dir string, remaining int64) (int64, error) {
+	tr := tar.NewReader(r)
+	var size int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+		name := cleanArtifactPath(header.Name)
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return size, err
+			}
+		case tar.TypeReg:
+			if remaining >= 0 && size+header.Size > remaining {
+				return size, fmt.Errorf("size limit of artifacts exceeded by %s", name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return size, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return size, err
+			}
+			n, err := io.Copy(f, tr)
+			size += n
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return size, err
+			}
+			os.Chtimes(target, header.ModTime, header.ModTime)
+		}
+	}
+}
+
+// collectArtifacts copies artifacts from the container into run's artifacts
+// directory. Missing artifacts and artifacts exceeding the size limit are
+// reported in the run's log.
+func (m *TaskManager) collectArtifacts(logger Logger, containerID string, paths []string) {
+	dir := logger.ArtifactsDir()
+	remaining := int64(-1)
+	if limit := logger.ArtifactsLimit(); limit > 0 {
+		remaining = limit
+	}
+	for _, src := range paths {
+		err := func() error {
+			content, _, err := m.Cli.CopyFromContainer(m.Ctx, containerID, src)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+			size, err := extractArtifacts(content, dir, remaining)
+			if remaining >= 0 {
+				remaining -= size
+			}
+			return err
+		}()
+		if err != nil {
+			LogWarn("Failed to copy artifact from container", logger.Fields().With("path", src).With("error", err))
+			fmt.Fprintf(logger.StderrWriter(), "[CRON] Failed to collect artifact %s: %s\n", src, err)
+		}
+	}
+}
+
+// artifactsSize returns total size of files in artifacts directory
+func artifactsSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func (m *TaskManager) maxArtifactsSize(task string) byteSize {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if t, ok := m.Tasks[task]; ok && t.logs.MaxArtifactsSize != 0 {
+		return t.logs.MaxArtifactsSize
+	}
+	return m.Config.Logs.MaxArtifactsSize
+}
+
+// ListArtifacts returns files collected from container of the task run
+func (m *TaskManager) ListArtifacts(task string, id int) ([]artifactInfo, error) {
+	root := m.GetArtifactsPath(task, id)
+	artifacts := make([]artifactInfo, 0)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return artifacts, nil
+	}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, artifactInfo{filepath.ToSlash(rel), info.Size(), info.ModTime()})
+		return nil
+	})
+	return artifacts, err
+}
+
+// OpenArtifact opens artifact file of the task run
+func (m *TaskManager) OpenArtifact(task string, id int, name string) (*os.File, error) {
+	name = cleanArtifactPath(name)
+	if name == "" {
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(m.GetArtifactsPath(task, id), filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+func validateArtifacts(paths []string) error {
+	for _, p := range paths {
+		if !path.IsAbs(p) || cleanArtifactPath(p) == "" {
+			return fmt.Errorf("invalid artifact path %q (absolute path of file or directory expected)", p)
+		}
+	}
+	return nil
+}
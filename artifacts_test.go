@@ -0,0 +1,138 @@
+package dcron
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"out/report.html", "out/data.csv", "../escape.txt"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	return &buf
+}
+
+func TestExtractArtifacts(t *testing.T) {
+	files := map[string]string{"out/report.html": "<html>", "out/data.csv": "a,b\n1,2\n"}
+	tests := []struct {
+		name      string
+		files     map[string]string
+		remaining int64
+		extracted []string
+		err       string
+	}{
+		{"unlimited", files, -1, []string{"out/data.csv", "out/report.html"}, ""},
+		{"within limit", files, 14, []string{"out/data.csv", "out/report.html"}, ""},
+		{"exceeding limit", files, 10, []string{"out/report.html"}, "size limit of artifacts exceeded by out/data.csv"},
+		{"path outside directory", map[string]string{"../escape.txt": "x"}, -1, []string{"escape.txt"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "dcron-artifacts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			root := filepath.Join(dir, "backup.1")
+			size, err := extractArtifacts(testArchive(t, test.files), root, test.remaining)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+			m := &TaskManager{LogsRoot: dir}
+			artifacts, err := m.ListArtifacts("backup", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			var total int64
+			for _, artifact := range artifacts {
+				paths = append(paths, artifact.Path)
+				total += artifact.Size
+			}
+			if strings.Join(paths, " ") != strings.Join(test.extracted, " ") {
+				t.Errorf("extracted %v, expected %v", paths, test.extracted)
+			}
+			if size != total || artifactsSize(root) != total {
+				t.Errorf("size %d, artifacts size %d, expected %d", size, artifactsSize(root), total)
+			}
+		})
+	}
+}
+
+func TestLogFilesIncludeArtifactsSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := &TaskManager{LogsRoot: dir, Stats: &tasksStats{}}
+	if err := ioutil.WriteFile(m.GetLogfilePath("backup", 1), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractArtifacts(testArchive(t, map[string]string{"out/report.html": "<html>"}), m.GetArtifactsPath("backup", 1), -1); err != nil {
+		t.Fatal(err)
+	}
+	files, err := m.logFiles(m.Stats.Tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files["backup"]) != 1 || files["backup"][0].size != 106 {
+		t.Errorf("unexpected log files: %+v", files["backup"])
+	}
+}
+
+func TestArtifactDownloadUnknownTask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := newTestTaskManager()
+	m.LogsRoot = dir
+	if _, err := m.LoadConfig(testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	// artifacts left by removed task
+	for _, task := range []string{"backup", "removed"} {
+		if _, err := extractArtifacts(testArchive(t, map[string]string{"out/report.html": "<html>"}), m.GetArtifactsPath(task, 1), -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(m)
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/api/tasks/backup/runs/1/artifacts/out/report.html", http.StatusOK},
+		{"/api/tasks/removed/runs/1/artifacts/out/report.html", http.StatusNotFound},
+		{"/api/tasks/backup/runs/1/artifacts/out/missing.html", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if rec.Code != test.status {
+			t.Errorf("GET %s: status %d, expected %d", test.url, rec.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package dcron
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Catch-up policies for scheduled runs missed while dcron was not running
+const (
+	catchupNone = "none"
+	catchupLast = "last"
+	catchupAll  = "all"
+)
+
+// maxCatchupRuns limits number of missed runs executed with "all" policy
+const maxCatchupRuns = 100
+
+// scheduleState persistent record of the last scheduled fire time of tasks
+type scheduleState struct {
+	sync.Mutex
+	path     string
+	LastFire map[string]time.Time `json:"last_fire"`
+}
+
+func loadScheduleState(path string) *scheduleState {
+	state := &scheduleState{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, state)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		LogError("Failed to load schedule state", Fields{"path": path, "error": err})
+	}
+	if state.LastFire == nil {
+		state.LastFire = make(map[string]time.Time)
+	}
+	return state
+}
+
+func (s *scheduleState) save() {
+	data, err := json.Marshal(s)
+	if err == nil {
+		err = writeFile(s.path, data)
+	}
+	if err != nil {
+		LogError("Failed to save schedule state", Fields{"path": s.path, "error": err})
+	}
+}
+
+func (s *scheduleState) Get(task string) (time.Time, bool) {
+	s.Lock()
+	defer s.Unlock()
+	t, ok := s.LastFire[task]
+	return t, ok
+}
+
+func (s *scheduleState) Set(task string, t time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.LastFire[task] = t
+	s.save()
+}
+
+// countMissedRuns number of schedule's fire times in interval (last, now>
+func countMissedRuns(schedule string, last, now time.Time, limit int) (int, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for t := sched.Next(last); !t.IsZero() && !t.After(now) && count < limit; t = sched.Next(t) {
+		count++
+	}
+	return count, nil
+}
+
+// UpcomingTimes computes fire times of cron schedule after given time, up to count
+// times or until given time (if not zero)
+func UpcomingTimes(schedule string, from time.Time, count int, until time.Time) ([]time.Time, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, 0)
+	for t := sched.Next(from); !t.IsZero() && len(times) < count; t = sched.Next(t) {
+		if !until.IsZero() && t.After(until) {
+			break
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// missedRuns records fire time of scheduled task and returns number of runs
+// missed since its last recorded fire time to be caught up (by its policy)
+func (m *TaskManager) missedRuns(task *Task, now time.Time) int {
+	last, ok := m.state.Get(task.Name)
+	m.state.Set(task.Name, now)
+	if !ok || task.Catchup == "" || task.Catchup == catchupNone {
+		return 0
+	}
+	missed, err := countMissedRuns(task.Schedule, last, now, maxCatchupRuns)
+	if err != nil || missed == 0 {
+		return 0
+	}
+	if task.Catchup == catchupLast {
+		missed = 1
+	}
+	LogInfo("Catching up missed runs", Fields{"task": task.Name, "missed": missed, "since": last.Format(time.RFC3339)})
+	return missed
+}
+
+// catchUp executes missed runs of the task, remaining runs are skipped when
+// the task is already running (e.g. started by its schedule)
+func (m *TaskManager) catchUp(task *Task, missed int) {
+	for i := 0; i < missed; i++ {
+		if m.isTaskRunning(task.Name) {
+			LogInfo("Task is running, skipping catch-up runs", Fields{"task": task.Name, "skipped": missed - i})
+			return
+		}
+		m.runTask(task, true)
+	}
+}
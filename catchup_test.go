@@ -0,0 +1,133 @@
+package dcron
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCountMissedRuns(t *testing.T) {
+	at := func(value string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+		return t
+	}
+	tests := []struct {
+		name     string
+		schedule string
+		last     time.Time
+		now      time.Time
+		limit    int
+		missed   int
+	}{
+		{"downtime window", "0 * * * *", at("2020-05-01 10:30"), at("2020-05-01 13:45"), 100, 3},
+		{"fire time at restart", "0 * * * *", at("2020-05-01 10:30"), at("2020-05-01 13:00"), 100, 3},
+		{"last fire time excluded", "0 * * * *", at("2020-05-01 10:00"), at("2020-05-01 10:59"), 100, 0},
+		{"no downtime", "0 * * * *", at("2020-05-01 10:30"), at("2020-05-01 10:30"), 100, 0},
+		{"limited", "* * * * *", at("2020-05-01 10:00"), at("2020-05-02 10:00"), 100, 100},
+		{"daily", "@daily", at("2020-05-01 10:00"), at("2020-05-04 10:00"), 100, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missed, err := countMissedRuns(test.schedule, test.last, test.now, test.limit)
+			if err != nil || missed != test.missed {
+				t.Errorf("missed runs %d (%v), expected %d", missed, err, test.missed)
+			}
+		})
+	}
+	if _, err := countMissedRuns("every hour", time.Now(), time.Now(), 100); err == nil {
+		t.Error("invalid schedule accepted")
+	}
+}
+
+func testScheduleState(t *testing.T) (*scheduleState, func()) {
+	dir, err := ioutil.TempDir("", "dcron-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loadScheduleState(filepath.Join(dir, "schedule.json")), func() { os.RemoveAll(dir) }
+}
+
+func TestMissedRunsPolicies(t *testing.T) {
+	now := time.Date(2020, 5, 1, 13, 45, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		catchup string
+		last    time.Duration
+		missed  int
+	}{
+		{"none", catchupNone, 3 * time.Hour, 0},
+		{"not set", "", 3 * time.Hour, 0},
+		{"last", catchupLast, 3 * time.Hour, 1},
+		{"all", catchupAll, 3*time.Hour + 30*time.Minute, 3},
+		{"no missed runs", catchupAll, 30 * time.Second, 0},
+		{"no recorded fire time", catchupAll, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, cleanup := testScheduleState(t)
+			defer cleanup()
+			m := &TaskManager{state: state}
+			if test.last > 0 {
+				state.LastFire["backup"] = now.Add(-test.last)
+			}
+			task := &Task{Name: "backup", Schedule: "0 * * * *", Catchup: test.catchup}
+			if missed := m.missedRuns(task, now); missed != test.missed {
+				t.Errorf("missed runs %d, expected %d", missed, test.missed)
+			}
+			if last, ok := state.Get("backup"); !ok || !last.Equal(now) {
+				t.Errorf("fire time is not recorded: %v", last)
+			}
+		})
+	}
+}
+
+func TestCatchUpSkipsRunningTask(t *testing.T) {
+	runs := 0
+	task := &Task{Name: "backup", Run: func(Logger) (int, error) {
+		runs++
+		return 0, nil
+	}}
+	m := &TaskManager{Stats: &tasksStats{Tasks: map[string][]*TaskStats{
+		"backup": {{ID: 1, Running: true}},
+	}}}
+	m.catchUp(task, 3)
+	if runs != 0 || len(m.Stats.Tasks["backup"]) != 1 {
+		t.Errorf("catch-up run started while the task is running")
+	}
+}
+
+func TestLoadScheduleState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.json")
+
+	// missing file
+	state := loadScheduleState(path)
+	if _, ok := state.Get("backup"); ok || state.LastFire == nil {
+		t.Errorf("unexpected state of missing file: %+v", state)
+	}
+
+	fired := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	state.Set("backup", fired)
+	if last, ok := loadScheduleState(path).Get("backup"); !ok || !last.Equal(fired) {
+		t.Errorf("fire time is not persisted: %v", last)
+	}
+
+	// corrupt file
+	if err := ioutil.WriteFile(path, []byte(`{"last_fire": {"backup": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state = loadScheduleState(path)
+	if _, ok := state.Get("backup"); ok || state.LastFire == nil {
+		t.Errorf("unexpected state of corrupt file: %+v", state)
+	}
+	state.Set("backup", fired)
+	if last, ok := loadScheduleState(path).Get("backup"); !ok || !last.Equal(fired) {
+		t.Errorf("corrupt state is not replaced: %v", last)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/marcel-dancak/dcron"
+)
+
+// loadConfig loads tasks configuration like the scheduler, with changes made
+// through API (overlay file) applied
+func loadConfig(paths []string) (dcron.TasksConfig, error) {
+	store := dcron.NewConfigStore(paths, optEnv("DCRON_CONFIG_OVERLAY", dcron.DefaultOverlayPath(paths)))
+	return store.Load()
+}
+
+// validateCommand checks given config files and directories (or DCRON_CONFIG_FILE),
+// returns exit code
+func validateCommand(args []string) int {
+	paths := args
+	if len(paths) == 0 {
+		paths = filepath.SplitList(os.Getenv("DCRON_CONFIG_FILE"))
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: dcron validate <file|dir>...")
+		return 2
+	}
+	files, err := dcron.ConfigFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	code := 0
+	for _, path := range files {
+		errors := dcron.ValidateConfigFile(path)
+		for _, err := range errors {
+			if e, ok := err.(dcron.ConfigError); ok && e.Line > 0 {
+				fmt.Fprintf(os.Stderr, "%s:%d: %s\n", path, e.Line, e.Msg)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			}
+		}
+		if len(errors) > 0 {
+			code = 1
+		} else {
+			fmt.Printf("%s: OK\n", path)
+		}
+	}
+	if code == 0 {
+		// conflicts between files and with the overlay
+		if _, err := loadConfig(paths); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// parseArgs parses flags mixed with positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// nextCommand prints upcoming fire times of scheduled tasks, returns exit code
+func nextCommand(args []string) int {
+	flags := flag.NewFlagSet("next", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("DCRON_CONFIG_FILE"), "tasks configuration files or directories (separated by ':')")
+	count := flags.Int("count", 10, "number of fire times per task")
+	untilArg := flags.String("until", "", "list fire times until given time (RFC3339)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: dcron next [task] [--count N] [--until TIME] [--config FILE]")
+		flags.PrintDefaults()
+	}
+	names, err := parseArgs(flags, args)
+	if err != nil {
+		return 2
+	}
+	if *configPath == "" || len(names) > 1 {
+		flags.Usage()
+		return 2
+	}
+	var until time.Time
+	if *untilArg != "" {
+		if until, err = time.Parse(time.RFC3339, *untilArg); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid until time:", err)
+			return 2
+		}
+	}
+	countSet := false
+	flags.Visit(func(f *flag.Flag) { countSet = countSet || f.Name == "count" })
+	if !until.IsZero() && !countSet {
+		*count = 1000
+	}
+	config, err := loadConfig(filepath.SplitList(*configPath))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	schedules := make(map[string]string)
+	for name, task := range config.Run {
+		schedules[name] = task.Schedule
+	}
+	for name, task := range config.Exec {
+		schedules[name] = task.Schedule
+	}
+	if len(names) == 1 {
+		schedule, ok := schedules[names[0]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Task not found: %s\n", names[0])
+			return 1
+		}
+		schedules = map[string]string{names[0]: schedule}
+	} else {
+		for name := range schedules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	code := 0
+	now := time.Now()
+	for _, name := range names {
+		schedule := schedules[name]
+		if schedule == "" {
+			continue
+		}
+		times, err := dcron.UpcomingTimes(schedule, now, *count, until)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s (%s)\n", name, schedule)
+		for _, t := range times {
+			fmt.Printf("  %s\n", t.Format("Mon 2006-01-02 15:04:05 MST"))
+		}
+	}
+	return code
+}
+
+// schemaCommand prints JSON Schema of tasks configuration, returns exit code
+func schemaCommand() int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dcron.ConfigSchema()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -2,18 +2,17 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/marcel-dancak/dcron"
-	"gopkg.in/yaml.v2"
 )
 
 func optEnv(key, defaultValue string) string {
@@ -24,86 +23,157 @@ func optEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func parseConfig(path string) (dcron.TasksConfig, error) {
-	config := dcron.TasksConfig{}
-	data, err := ioutil.ReadFile(path)
+// watchConfig watches config files and directories, reload is called (once
+// for a burst of events) when a file is changed, added, removed or renamed
+func watchConfig(paths []string, reload func()) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return config, err
+		return nil, err
+	}
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs[path] = true
+		} else {
+			files[path] = true
+		}
 	}
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return config, err
+	watch := func() {
+		for _, path := range paths {
+			if err := watcher.Add(path); err != nil {
+				dcron.LogError("Failed to watch config path", dcron.Fields{"path": path, "error": err})
+			}
+		}
+	}
+
+	go func() {
+		pendingReload := false
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Clean(event.Name)
+				dir, base := filepath.Split(name)
+				watched := files[name] || (dirs[filepath.Clean(dir)] && (dcron.IsConfigFile(name) || base == ".env"))
+				if event.Op == fsnotify.Chmod || !watched {
+					continue
+				}
+				if !pendingReload {
+					pendingReload = true
+					time.AfterFunc(1*time.Second, func() {
+						dcron.LogInfo("Reloading configuration", dcron.Fields{"path": event.Name})
+						reload()
+						// editors may replace files (watch of removed file is lost)
+						watch()
+						pendingReload = false
+					})
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				dcron.LogError("Failed to watch config files", dcron.Fields{"error": err})
+			}
+		}
+	}()
+	watch()
+	return watcher, nil
+}
+
+func checkCompose(compose *dcron.ComposeProject, config dcron.TasksConfig) {
+	if compose != nil {
+		for _, warning := range compose.Check(config) {
+			dcron.LogWarn(warning, nil)
+		}
 	}
-	return config, nil
 }
 
 func main() {
-	configPath := os.Getenv("DCRON_CONFIG_FILE")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "next":
+			os.Exit(nextCommand(os.Args[2:]))
+		case "schema":
+			os.Exit(schemaCommand())
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
+			fmt.Fprintln(os.Stderr, "Usage: dcron [validate <file>... | next [task] | schema]")
+			os.Exit(2)
+		}
+	}
+	serve()
+}
+
+func serve() {
+	logFormat, logLevel := os.Getenv("DCRON_LOG_FORMAT"), os.Getenv("DCRON_LOG_LEVEL")
+	if err := dcron.ConfigureLogging(logFormat, logLevel, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+	configPaths := filepath.SplitList(os.Getenv("DCRON_CONFIG_FILE"))
 	projectName := os.Getenv("DCRON_COMPOSE_PROJECT")
-	if configPath == "" {
-		log.Fatal("Config file not specified!")
+	if len(configPaths) == 0 {
+		dcron.LogFatal("Config file not specified!", nil)
+	}
+	var compose *dcron.ComposeProject
+	if composeFile := os.Getenv("DCRON_COMPOSE_FILE"); composeFile != "" {
+		var err error
+		if compose, err = dcron.LoadComposeFile(composeFile, projectName); err != nil {
+			dcron.LogFatal("Failed to read compose file", dcron.Fields{"path": composeFile, "error": err})
+		}
+		projectName = compose.Name
 	}
 	if projectName == "" {
-		log.Fatal("Docker Compose project's name not specified!")
+		dcron.LogFatal("Docker Compose project's name not specified!", nil)
 	}
 
-	config, err := parseConfig(configPath)
+	store := dcron.NewConfigStore(configPaths, optEnv("DCRON_CONFIG_OVERLAY", dcron.DefaultOverlayPath(configPaths)))
+	config, err := store.Load()
 	if err != nil {
-		log.Printf("[CRON] Failed to parse config file: %s\n", configPath)
-		log.Fatal(err)
+		dcron.LogFatal("Failed to parse config files", dcron.Fields{"path": strings.Join(configPaths, ", "), "error": err})
 	}
 
 	logsDir := filepath.Join(optEnv("DCRON_LOGS_ROOT", "/var/log/dcron"), projectName)
 	tm, err := dcron.NewTaskManager(config, projectName, logsDir)
 	if err != nil {
-		log.Println("Failed to initialize Task Manager")
-		log.Fatal(err)
+		dcron.LogFatal("Failed to initialize Task Manager", dcron.Fields{"error": err})
 	}
+	dcron.ConfigureLogging(logFormat, logLevel, tm.RedactWriter(os.Stderr))
+	tm.Compose = compose
+	tm.ConfigStore = store
+	checkCompose(compose, config)
 
 	tm.Start()
-	log.Println("[CRON] Starting Cron Jobs")
+	dcron.LogInfo("Starting Cron Jobs", nil)
 
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := watchConfig(configPaths, func() {
+		conf, err := store.Load()
+		if err != nil {
+			dcron.LogError("Failed to parse config files", dcron.Fields{"path": strings.Join(configPaths, ", "), "error": err})
+			return
+		}
+		checkCompose(compose, conf)
+		diff, err := tm.LoadConfig(conf)
+		if err != nil {
+			dcron.LogError("Failed to load configuration", dcron.Fields{"error": err})
+			return
+		}
+		dcron.LogInfo("Configuration reloaded", dcron.Fields{
+			"added":   len(diff.Added),
+			"removed": len(diff.Removed),
+			"changed": len(diff.Changed),
+		})
+	})
 	if err != nil {
-		log.Fatal(err)
+		dcron.LogFatal("Failed to watch config files", dcron.Fields{"error": err})
 	}
 	defer watcher.Close()
 
-	go func() {
-		pendingReload := false
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					if !pendingReload {
-						pendingReload = true
-						time.AfterFunc(1*time.Second, func() {
-							log.Println("Reloading configuration file:", event.Name)
-							conf, err := parseConfig(configPath)
-							if err != nil {
-								log.Printf("[CRON] Failed to parse config file: %s\n", configPath)
-								log.Println(err)
-							} else {
-								tm.Stop()
-								tm.LoadConfig(conf)
-								tm.Start()
-							}
-							pendingReload = false
-						})
-					}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Println("error:", err)
-			}
-		}
-	}()
-	watcher.Add(configPath)
-
 	webPort, webServerConfigured := os.LookupEnv("DCRON_WEB_PORT")
 	if webServerConfigured {
 		webAddress := fmt.Sprintf(":%s", webPort)
@@ -115,24 +185,27 @@ func main() {
 		}
 		publicServer := dcron.NewPublicServer(tm, optEnv("DCRON_WEB_ROOT", "/var/www"), auth)
 		certFile, useSSL := os.LookupEnv("DCRON_SSL_CERT")
-		log.Println("[CRON] Starting public web server on port:", webPort)
+		dcron.LogInfo("Starting public web server", dcron.Fields{"port": webPort})
 		if useSSL {
 			keyFile, _ := os.LookupEnv("DCRON_SSL_CERT_KEY")
 			go func() {
-				log.Fatal(http.ListenAndServeTLS(webAddress, certFile, keyFile, publicServer))
+				err := http.ListenAndServeTLS(webAddress, certFile, keyFile, publicServer)
+				dcron.LogFatal("Public web server failed", dcron.Fields{"error": err})
 			}()
 		} else {
 			go func() {
-				log.Fatal(http.ListenAndServe(webAddress, publicServer))
+				err := http.ListenAndServe(webAddress, publicServer)
+				dcron.LogFatal("Public web server failed", dcron.Fields{"error": err})
 			}()
 		}
 	}
 
 	apiServer := dcron.NewServer(tm)
 	apiPort := optEnv("DCRON_API_PORT", "7000")
-	log.Println("[CRON] Starting API server on port:", apiPort)
+	dcron.LogInfo("Starting API server", dcron.Fields{"port": apiPort})
 	go func() {
-		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", apiPort), apiServer))
+		err := http.ListenAndServe(fmt.Sprintf(":%s", apiPort), apiServer)
+		dcron.LogFatal("API server failed", dcron.Fields{"error": err})
 	}()
 
 	done := make(chan os.Signal, 1)
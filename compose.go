@@ -0,0 +1,127 @@
+package dcron
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type composeResource struct {
+	Name string `yaml:"name"`
+	// true or (deprecated) {name: ...}
+	External interface{} `yaml:"external"`
+}
+
+type composeFile struct {
+	Name     string                      `yaml:"name"`
+	Services map[string]interface{}      `yaml:"services"`
+	Volumes  map[string]*composeResource `yaml:"volumes"`
+	Networks map[string]*composeResource `yaml:"networks"`
+}
+
+// ComposeProject Docker Compose project settings read from docker-compose file
+type ComposeProject struct {
+	Name     string
+	Services map[string]bool
+	// real names of volumes and networks defined in compose file
+	Volumes  map[string]string
+	Networks map[string]string
+}
+
+var projectNameRe = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+func (r *composeResource) realName(project, name string) string {
+	if r != nil {
+		if external, ok := r.External.(map[interface{}]interface{}); ok && external["name"] != nil {
+			return fmt.Sprint(external["name"])
+		}
+		if r.Name != "" {
+			return r.Name
+		}
+		if external, ok := r.External.(bool); ok && external {
+			return name
+		}
+	}
+	return fmt.Sprintf("%s_%s", project, name)
+}
+
+// LoadComposeFile reads docker-compose file. Project name is taken from
+// the project argument (if not empty), compose file's name: field or
+// COMPOSE_PROJECT_NAME variable (environment or .env file next to compose file)
+func LoadComposeFile(path, project string) (*ComposeProject, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	compose := composeFile{}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, err
+	}
+	if project == "" {
+		project = compose.Name
+	}
+	if project == "" {
+		project = os.Getenv("COMPOSE_PROJECT_NAME")
+	}
+	if project == "" {
+		env, err := loadEnvFile(filepath.Join(filepath.Dir(path), ".env"))
+		if err != nil {
+			return nil, err
+		}
+		project = env["COMPOSE_PROJECT_NAME"]
+	}
+	project = projectNameRe.ReplaceAllString(strings.ToLower(project), "")
+	if project == "" {
+		return nil, fmt.Errorf("%s: project name not specified", path)
+	}
+
+	p := &ComposeProject{
+		Name:     project,
+		Services: make(map[string]bool, len(compose.Services)),
+		Volumes:  make(map[string]string, len(compose.Volumes)),
+		Networks: make(map[string]string, len(compose.Networks)+1),
+	}
+	for name := range compose.Services {
+		p.Services[name] = true
+	}
+	for name, volume := range compose.Volumes {
+		p.Volumes[name] = volume.realName(project, name)
+	}
+	p.Networks["default"] = fmt.Sprintf("%s_default", project)
+	for name, network := range compose.Networks {
+		p.Networks[name] = network.realName(project, name)
+	}
+	return p, nil
+}
+
+var builtinNetworks = map[string]bool{"": true, "default": true, "bridge": true, "host": true, "none": true}
+
+// Check returns warnings about services, volumes and networks referenced
+// by tasks, which are not defined in compose file
+func (p *ComposeProject) Check(config TasksConfig) []string {
+	var warnings []string
+	for name, task := range config.Run {
+		for _, volume := range task.Volumes {
+			src := strings.Split(volume, ":")[0]
+			if _, ok := p.Volumes[src]; !ok && !strings.Contains(src, "/") {
+				warnings = append(warnings, fmt.Sprintf("task %q: volume %q is not defined in compose file", name, src))
+			}
+		}
+		network := task.NetworkMode
+		if _, ok := p.Networks[network]; !ok && !builtinNetworks[network] && !strings.Contains(network, ":") {
+			warnings = append(warnings, fmt.Sprintf("task %q: network %q is not defined in compose file", name, network))
+		}
+	}
+	for name, task := range config.Exec {
+		if !p.Services[task.Service] {
+			warnings = append(warnings, fmt.Sprintf("task %q: service %q is not defined in compose file", name, task.Service))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
@@ -0,0 +1,184 @@
+package dcron
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compression policies of finished logs (or age of logs as duration)
+const (
+	compressNever  = "never"
+	compressFinish = "finish"
+)
+
+// logCompression when finished logs are compressed: "never", "finish" (when
+// the run finishes) or age of the log (e.g. 24h). YAML booleans are accepted too.
+type logCompression string
+
+func (c *logCompression) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			*c = compressFinish
+		} else {
+			*c = compressNever
+		}
+	case string:
+		*c = logCompression(v)
+	default:
+		return unmarshalError("invalid compression %v", value)
+	}
+	return nil
+}
+
+// age returns age of logs to be compressed
+func (c logCompression) age() (time.Duration, bool) {
+	switch c {
+	case "", compressNever:
+		return 0, false
+	case compressFinish:
+		return 0, true
+	}
+	age, err := time.ParseDuration(string(c))
+	return age, err == nil
+}
+
+func (c logCompression) validate() error {
+	switch c {
+	case "", compressNever, compressFinish:
+		return nil
+	}
+	if age, err := time.ParseDuration(string(c)); err != nil || age < 0 {
+		return fmt.Errorf("invalid compression %q (never, finish or age of logs, e.g. 24h)", string(c))
+	}
+	return nil
+}
+
+// compressLog replaces log file by its gzipped version, log already
+// compressed by concurrent pass (after the run or periodic) is skipped
+func compressLog(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.gz.tmp")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(dst.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(dst.Name(), path+".gz")
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *TaskManager) logCompression(task string) logCompression {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if t, ok := m.Tasks[task]; ok && t.logs.Compress != "" {
+		return t.logs.Compress
+	}
+	return m.Config.Logs.Compress
+}
+
+func (m *TaskManager) maxLogSize(task string) byteSize {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if t, ok := m.Tasks[task]; ok && t.logs.MaxLogSize != 0 {
+		return t.logs.MaxLogSize
+	}
+	return m.Config.Logs.MaxLogSize
+}
+
+// CompressLogs compresses finished logs older than configured age
+func (m *TaskManager) CompressLogs() {
+	files, err := m.logFiles(m.statsSnapshot())
+	if err != nil {
+		LogError("Failed to read logs directory", Fields{"error": err})
+		return
+	}
+	now := time.Now()
+	for task, taskFiles := range files {
+		age, ok := m.logCompression(task).age()
+		if !ok {
+			continue
+		}
+		for _, file := range taskFiles {
+			if file.compressed || now.Sub(file.modTime) < age {
+				continue
+			}
+			m.Stats.RLock()
+			running := file.stats != nil && file.stats.Running
+			m.Stats.RUnlock()
+			if running {
+				continue
+			}
+			if err := compressLog(file.path); err != nil {
+				LogError("Failed to compress log file", Fields{"task": task, "path": file.path, "error": err})
+			}
+		}
+	}
+}
+
+// OpenLog opens log file of the task run, which can be gzip compressed
+func (m *TaskManager) OpenLog(task string, id int) (f *os.File, compressed bool, err error) {
+	path := m.GetLogfilePath(task, id)
+	f, err = os.Open(path)
+	if os.IsNotExist(err) {
+		f, err = os.Open(path + ".gz")
+		return f, err == nil, err
+	}
+	return f, false, err
+}
+
+// acceptsGzip reports whether HTTP client accepts gzip content encoding
+func acceptsGzip(header string) bool {
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
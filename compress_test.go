@@ -0,0 +1,84 @@
+package dcron
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCompressLogConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.1.log")
+	content := `{"log":"done\n","stream":"stdout","time":"2020-01-01T00:00:00Z"}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errors := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errors <- compressLog(path)
+		}()
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		if err != nil {
+			t.Errorf("compression failed: %s", err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "backup.1.log.gz" {
+		for _, f := range files {
+			t.Errorf("unexpected file %s", f.Name())
+		}
+	}
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil || string(data) != content {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header  string
+		accepts bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"gzip, deflate, br", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0, deflate", false},
+		{"identity", false},
+		{"x-gzip", false},
+	}
+	for _, test := range tests {
+		if accepts := acceptsGzip(test.header); accepts != test.accepts {
+			t.Errorf("acceptsGzip(%q) = %t, expected %t", test.header, accepts, test.accepts)
+		}
+	}
+}
@@ -0,0 +1,643 @@
+package dcron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigError configuration error with its location in the config file
+type ConfigError struct {
+	Line int
+	Msg  string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return e.Msg
+}
+
+// ConfigErrors list of configuration errors
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ParseConfigFile reads tasks configuration file with interpolated variables
+func ParseConfigFile(path string) (TasksConfig, error) {
+	config, err := parseConfigFile(path)
+	if err != nil {
+		return config, err
+	}
+	return config, config.expand()
+}
+
+// parseConfigFile reads tasks configuration file with applied defaults,
+// matrix tasks are not expanded
+func parseConfigFile(path string) (TasksConfig, error) {
+	config := TasksConfig{}
+	data, err := readConfigFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	config.applyDefaults()
+	return config, nil
+}
+
+// expand expands matrix tasks and checks names of expanded tasks
+func (c *TasksConfig) expand() error {
+	if err := c.expandMatrix(); err != nil {
+		return err
+	}
+	return c.checkTaskNames()
+}
+
+// duplicateTaskNames returns (sorted) names of tasks defined in both run: and exec:
+func (c *TasksConfig) duplicateTaskNames() []string {
+	var names []string
+	for name := range c.Exec {
+		if _, ok := c.Run[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkTaskNames returns error when run and exec tasks share a name,
+// because all tasks are identified only by their names
+func (c *TasksConfig) checkTaskNames() error {
+	var errors ConfigErrors
+	for _, name := range c.duplicateTaskNames() {
+		errors = append(errors, fmt.Errorf("task %q is defined in both run: and exec:", name))
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// IsConfigFile reports whether file in config directory is a tasks configuration file
+func IsConfigFile(path string) bool {
+	ext := filepath.Ext(path)
+	base := filepath.Base(path)
+	return (ext == ".yml" || ext == ".yaml") && !strings.HasPrefix(base, ".") && !strings.HasSuffix(base, ".overlay.yml")
+}
+
+// ConfigFiles expands list of config paths, directories are replaced
+// with configuration files they contain (in alphabetical order)
+func ConfigFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && IsConfigFile(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// ParseConfigFiles reads and merges tasks configuration from multiple files
+// or directories, task names must be unique across all files
+func ParseConfigFiles(paths []string) (TasksConfig, error) {
+	config, _, err := parseConfigFiles(paths)
+	if err != nil {
+		return config, err
+	}
+	return config, config.expand()
+}
+
+// parseConfigFiles merges configuration files without expanding matrix tasks,
+// returns also defaults of the first file
+func parseConfigFiles(paths []string) (TasksConfig, tasksDefaults, error) {
+	config := TasksConfig{Run: make(map[string]runTask), Exec: make(map[string]execTask)}
+	var defaults tasksDefaults
+	files, err := ConfigFiles(paths)
+	if err != nil {
+		return config, defaults, err
+	}
+	config.Secrets = make(map[string]secretDefinition)
+	sources := make(map[string]string)
+	secretSources := make(map[string]string)
+	logsSource := ""
+	notificationsSource := ""
+	var errors ConfigErrors
+	define := func(name, file string) bool {
+		if source, ok := sources[name]; ok && source != file {
+			errors = append(errors, fmt.Errorf("task %q is defined in both %s and %s", name, source, file))
+			return false
+		}
+		sources[name] = file
+		return true
+	}
+	for i, file := range files {
+		fileConfig, err := parseConfigFile(file)
+		if err == nil {
+			expanded := fileConfig
+			err = expanded.expand()
+		}
+		if err != nil {
+			return config, defaults, fmt.Errorf("%s: %s", file, err)
+		}
+		if i == 0 {
+			defaults = fileConfig.Defaults
+		}
+		if !isZero(reflect.ValueOf(fileConfig.Logs)) {
+			if logsSource != "" {
+				errors = append(errors, fmt.Errorf("logs retention is defined in both %s and %s", logsSource, file))
+			} else {
+				logsSource = file
+				config.Logs = fileConfig.Logs
+			}
+		}
+		if len(fileConfig.Notifications) > 0 {
+			if notificationsSource != "" {
+				errors = append(errors, fmt.Errorf("notifications are defined in both %s and %s", notificationsSource, file))
+			} else {
+				notificationsSource = file
+				config.Notifications = fileConfig.Notifications
+			}
+		}
+		for name, secret := range fileConfig.Secrets {
+			if source, ok := secretSources[name]; ok {
+				errors = append(errors, fmt.Errorf("secret %q is defined in both %s and %s", name, source, file))
+				continue
+			}
+			secretSources[name] = file
+			config.Secrets[name] = secret
+		}
+		for name, task := range fileConfig.Run {
+			if define(name, file) {
+				config.Run[name] = task
+			}
+		}
+		for name, task := range fileConfig.Exec {
+			if define(name, file) {
+				config.Exec[name] = task
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return config, defaults, errors
+	}
+	return config, defaults, nil
+}
+
+var errorLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func yamlErrors(err error) []error {
+	var messages []string
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	errors := make([]error, len(messages))
+	for i, msg := range messages {
+		if m := errorLineRe.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			errors[i] = ConfigError{line, m[2]}
+		} else {
+			errors[i] = ConfigError{0, msg}
+		}
+	}
+	return errors
+}
+
+// unmarshalError returns error of custom unmarshaler as type error,
+// so decoding of the rest of the document continues
+func unmarshalError(format string, args ...interface{}) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf(format, args...)}}
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// yamlFields maps YAML keys of struct fields to their types, including fields of inlined structs
+func yamlFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key, inline := yamlFieldName(field)
+		if (field.PkgPath != "" && !field.Anonymous) || key == "-" {
+			continue
+		}
+		if inline {
+			if field.Type.Kind() == reflect.Struct {
+				for key, fieldType := range yamlFields(field.Type) {
+					fields[key] = fieldType
+				}
+			}
+			continue
+		}
+		fields[key] = field.Type
+	}
+	return fields
+}
+
+// unmarshalErrors decodes document node by node to locate values rejected
+// by custom unmarshalers, whose errors have no line numbers
+func unmarshalErrors(node interface{}, typ reflect.Type, path []string, report func(path []string, msg string)) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PtrTo(typ).Implements(unmarshalerType) {
+		data, err := yaml.Marshal(node)
+		if err != nil {
+			return
+		}
+		if typeErr, ok := yaml.Unmarshal(data, reflect.New(typ).Interface()).(*yaml.TypeError); ok {
+			for _, msg := range typeErr.Errors {
+				// errors with line numbers are already reported with lines of the document
+				if !errorLineRe.MatchString(msg) {
+					report(path, msg)
+				}
+			}
+		}
+		return
+	}
+	items, ok := node.(yaml.MapSlice)
+	if !ok {
+		return
+	}
+	var fields map[string]reflect.Type
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields = yamlFields(typ)
+	case reflect.Map:
+	default:
+		return
+	}
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		itemType := typ
+		if fields != nil {
+			if itemType, ok = fields[key]; !ok {
+				continue
+			}
+		} else {
+			itemType = typ.Elem()
+		}
+		unmarshalErrors(item.Value, itemType, append(path[:len(path):len(path)], key), report)
+	}
+}
+
+var yamlKeyRe = regexp.MustCompile(`^(\s*)(?:(-)\s+)?("[^"]*"|'[^']*'|[^\s#'"][^:#]*?)\s*:(?:\s|$)`)
+var yamlItemRe = regexp.MustCompile(`^(\s*)-(?:\s|$)`)
+
+// configLines maps keys paths (and sequence items) of YAML document to line numbers.
+// It's only an approximation for block style documents, used for error reporting.
+type configLines map[string]int
+
+func linePath(path ...string) string {
+	return strings.Join(path, "\x00")
+}
+
+func newConfigLines(data []byte) configLines {
+	type level struct {
+		indent int
+		path   string
+	}
+	lines := make(configLines)
+	items := make(map[string]int)
+	stack := []level{}
+	parent := func(indent int, item bool) string {
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			// sequence items can be indented at the same level as their key
+			if top.indent < indent || (item && top.indent == indent) {
+				return top.path
+			}
+			stack = stack[:len(stack)-1]
+		}
+		return ""
+	}
+	for i, text := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+		if m := yamlKeyRe.FindStringSubmatch(text); m != nil && m[2] == "" {
+			indent := len(m[1])
+			key := strings.Trim(m[3], `"'`)
+			path := key
+			if p := parent(indent, false); p != "" {
+				path = linePath(p, key)
+			}
+			lines[path] = i + 1
+			stack = append(stack, level{indent, path})
+		} else if m := yamlItemRe.FindStringSubmatch(text); m != nil {
+			indent := len(m[1])
+			p := parent(indent, true)
+			path := linePath(p, strconv.Itoa(items[p]))
+			items[p]++
+			lines[path] = i + 1
+		}
+	}
+	return lines
+}
+
+// Line returns line number of the first existing path, starting with the most specific
+func (l configLines) Line(path ...string) int {
+	for i := len(path); i > 0; i-- {
+		if line, ok := l[linePath(path[:i]...)]; ok {
+			return line
+		}
+	}
+	return 0
+}
+
+var volumeNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var volumeModes = map[string]bool{
+	"ro": true, "rw": true, "z": true, "Z": true, "nocopy": true,
+	"consistent": true, "cached": true, "delegated": true,
+	"shared": true, "slave": true, "private": true,
+	"rshared": true, "rslave": true, "rprivate": true,
+}
+
+func validateVolume(volume string) error {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid volume %q, expected source:target[:mode]", volume)
+	}
+	src, dst := parts[0], parts[1]
+	if !strings.Contains(src, "/") && !volumeNameRe.MatchString(src) {
+		return fmt.Errorf("invalid volume name %q", src)
+	}
+	if !strings.HasPrefix(dst, "/") {
+		return fmt.Errorf("volume target %q must be an absolute path", dst)
+	}
+	if len(parts) == 3 {
+		for _, mode := range strings.Split(parts[2], ",") {
+			if !volumeModes[mode] {
+				return fmt.Errorf("invalid volume mode %q", mode)
+			}
+		}
+	}
+	return nil
+}
+
+var signalNames = map[string]bool{
+	"HUP": true, "INT": true, "QUIT": true, "ILL": true, "TRAP": true, "ABRT": true,
+	"BUS": true, "FPE": true, "KILL": true, "USR1": true, "SEGV": true, "USR2": true,
+	"PIPE": true, "ALRM": true, "TERM": true, "STKFLT": true, "CHLD": true, "CONT": true,
+	"STOP": true, "TSTP": true, "TTIN": true, "TTOU": true, "URG": true, "XCPU": true,
+	"XFSZ": true, "VTALRM": true, "PROF": true, "WINCH": true, "IO": true, "PWR": true,
+	"SYS": true,
+}
+
+// validateSignal checks signal name (e.g. SIGHUP or HUP) or number, as accepted by Docker
+func validateSignal(signal string) error {
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n < 1 || n > 64 {
+			return fmt.Errorf("invalid signal number %d", n)
+		}
+		return nil
+	}
+	if !signalNames[strings.TrimPrefix(strings.ToUpper(signal), "SIG")] {
+		return fmt.Errorf("invalid signal %q", signal)
+	}
+	return nil
+}
+
+type fieldError struct {
+	Field string
+	Msg   string
+}
+
+func validateBaseTask(task baseTask) []fieldError {
+	var errors []fieldError
+	if task.Schedule != "" {
+		if _, err := cron.ParseStandard(task.Schedule); err != nil {
+			errors = append(errors, fieldError{"schedule", err.Error()})
+		}
+	}
+	switch task.Catchup {
+	case "", catchupNone, catchupLast, catchupAll:
+	default:
+		errors = append(errors, fieldError{"catchup", fmt.Sprintf("invalid policy %q (none, last or all)", task.Catchup)})
+	}
+	errors = append(errors, validateLogs(task.Logs)...)
+	return append(errors, validateNotifications(task.Notifications)...)
+}
+
+// ValidateConfig strictly decodes tasks configuration and checks definitions of all tasks.
+// All found errors are returned, sorted by line number.
+func ValidateConfig(data []byte) []error {
+	config := TasksConfig{}
+	var errors, unlocated []error
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		// type errors don't stop decoding of the rest of the document
+		if _, ok := err.(*yaml.TypeError); !ok {
+			return yamlErrors(err)
+		}
+		for _, e := range yamlErrors(err) {
+			if e.(ConfigError).Line > 0 {
+				errors = append(errors, e)
+			} else {
+				unlocated = append(unlocated, e)
+			}
+		}
+	}
+
+	lines := newConfigLines(data)
+	add := func(line int, format string, args ...interface{}) {
+		errors = append(errors, ConfigError{line, fmt.Sprintf(format, args...)})
+	}
+	if len(unlocated) > 0 {
+		// errors of custom unmarshalers are reported with line and field
+		located := len(errors)
+		var document yaml.MapSlice
+		if yaml.Unmarshal(data, &document) == nil {
+			unmarshalErrors(document, reflect.TypeOf(config), nil, func(path []string, msg string) {
+				add(lines.Line(path...), "%s: %s", strings.Join(path, "."), msg)
+			})
+		}
+		if len(errors) == located {
+			errors = append(errors, unlocated...)
+		}
+	}
+	// unknown fields are collected in extensions, only x- fields are allowed
+	checkExtensions := func(extensions map[string]interface{}, path ...string) {
+		for key := range extensions {
+			if !strings.HasPrefix(key, "x-") {
+				line := lines.Line(append(path, key)...)
+				if len(path) == 0 {
+					add(line, "unknown field %q", key)
+				} else {
+					add(line, "%s: unknown field %q", strings.Join(path, "."), key)
+				}
+			}
+		}
+	}
+	// processes started by exec can't be killed through Docker API
+	rejectTimeout := func(extensions map[string]interface{}, path ...string) {
+		if _, ok := extensions["timeout"]; ok {
+			delete(extensions, "timeout")
+			add(lines.Line(append(path, "timeout")...), "%s.timeout: timeout is supported only by run tasks", strings.Join(path, "."))
+		}
+	}
+	checkExtensions(config.Extensions)
+	checkExtensions(config.Defaults.Run.Extensions, "defaults", "run")
+	rejectTimeout(config.Defaults.Exec.Extensions, "defaults", "exec")
+	checkExtensions(config.Defaults.Exec.Extensions, "defaults", "exec")
+	for name, task := range config.Run {
+		checkExtensions(task.Extensions, "run", name)
+	}
+	for name, task := range config.Exec {
+		rejectTimeout(task.Extensions, "exec", name)
+		checkExtensions(task.Extensions, "exec", name)
+	}
+	config.applyDefaults()
+	for name := range config.Run {
+		if err := validateTaskName(name); err != nil {
+			add(lines.Line("run", name), "run.%s: %s", name, err)
+		}
+	}
+	for name := range config.Exec {
+		if err := validateTaskName(name); err != nil {
+			add(lines.Line("exec", name), "exec.%s: %s", name, err)
+		}
+	}
+	for name, task := range config.Run {
+		if err := validateMatrix(task.Matrix, task.matrixTexts()...); err != nil {
+			add(lines.Line("run", name, "matrix"), "run.%s.matrix: %s", name, err)
+		}
+	}
+	for name, task := range config.Exec {
+		if err := validateMatrix(task.Matrix, append(task.matrixTexts(), task.Service)...); err != nil {
+			add(lines.Line("exec", name, "matrix"), "exec.%s.matrix: %s", name, err)
+		}
+	}
+	if len(errors) == 0 {
+		// tasks are validated as defined, expansion only checks names collisions
+		expanded := config
+		if err := expanded.expandMatrix(); err != nil {
+			for _, e := range err.(ConfigErrors) {
+				add(0, "%s", e)
+			}
+		} else {
+			// duplicates of tasks without matrix are reported below
+			for _, name := range expanded.duplicateTaskNames() {
+				_, run := config.Run[name]
+				_, exec := config.Exec[name]
+				if !run || !exec {
+					add(0, "task %q is defined in both run: and exec:", name)
+				}
+			}
+		}
+	}
+
+	for _, e := range append(validateLogs(config.Logs), validateNotifications(config.Notifications)...) {
+		add(lines.Line(strings.Split(e.Field, ".")...), "%s: %s", e.Field, e.Msg)
+	}
+	for name, secret := range config.Secrets {
+		if (secret.File == "") == (secret.Environment == "") {
+			add(lines.Line("secrets", name), "secrets.%s: exactly one of file or environment is required", name)
+		}
+	}
+	for name, task := range config.Run {
+		if err := validateSecrets(config.Secrets, task.Secrets, true); err != nil {
+			add(lines.Line("run", name, "secrets"), "run.%s.secrets: %s", name, err)
+		}
+	}
+	for name, task := range config.Exec {
+		if err := validateSecrets(config.Secrets, task.Secrets, false); err != nil {
+			add(lines.Line("exec", name, "secrets"), "exec.%s.secrets: %s", name, err)
+		}
+	}
+	for name, task := range config.Run {
+		for _, e := range validateBaseTask(task.baseTask) {
+			add(lines.Line(append([]string{"run", name}, strings.Split(e.Field, ".")...)...), "run.%s.%s: %s", name, e.Field, e.Msg)
+		}
+		if task.Image == "" {
+			add(lines.Line("run", name), "run.%s: image is required", name)
+		}
+		if task.Timeout != "" {
+			if timeout, err := time.ParseDuration(task.Timeout); err != nil || timeout <= 0 {
+				add(lines.Line("run", name, "timeout"), "run.%s.timeout: invalid duration %q (e.g. 30m)", name, task.Timeout)
+			}
+		}
+		if task.StopSignal != "" {
+			if err := validateSignal(task.StopSignal); err != nil {
+				add(lines.Line("run", name, "stop_signal"), "run.%s.stop_signal: %s", name, err)
+			}
+		}
+		if err := validateArtifacts(task.Artifacts); err != nil {
+			add(lines.Line("run", name, "artifacts"), "run.%s.artifacts: %s", name, err)
+		}
+		for i, volume := range task.Volumes {
+			if err := validateVolume(volume); err != nil {
+				add(lines.Line("run", name, "volumes", strconv.Itoa(i)), "run.%s.volumes: %s", name, err)
+			}
+		}
+	}
+	for name, task := range config.Exec {
+		for _, e := range validateBaseTask(task.baseTask) {
+			add(lines.Line(append([]string{"exec", name}, strings.Split(e.Field, ".")...)...), "exec.%s.%s: %s", name, e.Field, e.Msg)
+		}
+		if task.Service == "" {
+			add(lines.Line("exec", name), "exec.%s: service is required", name)
+		}
+		if len(task.Command) == 0 {
+			add(lines.Line("exec", name), "exec.%s: command is required", name)
+		}
+		if _, ok := config.Run[name]; ok {
+			add(lines.Line("exec", name), "task %q is defined in both run: and exec:", name)
+		}
+	}
+	sortErrors(errors)
+	return errors
+}
+
+// ValidateConfigFile validates tasks configuration file with interpolated variables
+func ValidateConfigFile(path string) []error {
+	data, err := readConfigFile(path)
+	if err != nil {
+		if errors, ok := err.(ConfigErrors); ok {
+			return errors
+		}
+		return []error{err}
+	}
+	return ValidateConfig(data)
+}
+
+func sortErrors(errors []error) {
+	sort.SliceStable(errors, func(i, j int) bool {
+		a, _ := errors[i].(ConfigError)
+		b, _ := errors[j].(ConfigError)
+		return a.Line < b.Line
+	})
+}
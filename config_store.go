@@ -0,0 +1,229 @@
+package dcron
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// overlayConfig tasks changed through API, applied over configuration files
+type overlayConfig struct {
+	Run     map[string]runTask  `yaml:"run,omitempty"`
+	Exec    map[string]execTask `yaml:"exec,omitempty"`
+	Removed []string            `yaml:"removed,omitempty"`
+}
+
+// Errors of configuration updates
+var (
+	ErrConfigChanged   = fmt.Errorf("Configuration files were changed since last load")
+	ErrVersionMismatch = fmt.Errorf("Configuration version doesn't match")
+)
+
+// InvalidConfigError configuration with applied changes failed to load
+type InvalidConfigError struct {
+	Err error
+}
+
+func (e InvalidConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// ConfigStore tasks configuration files with an overlay file, which
+// persists changes made through API
+type ConfigStore struct {
+	sync.Mutex
+	Paths   []string
+	Overlay string
+	version string
+	// defaults of the first configuration file
+	defaults tasksDefaults
+}
+
+// DefaultOverlayPath overlay file next to the first config file (or inside config directory)
+func DefaultOverlayPath(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	if info, err := os.Stat(paths[0]); err == nil && info.IsDir() {
+		return filepath.Join(paths[0], "tasks.overlay.yml")
+	}
+	ext := filepath.Ext(paths[0])
+	return strings.TrimSuffix(paths[0], ext) + ".overlay.yml"
+}
+
+// NewConfigStore creates config store for given config paths
+func NewConfigStore(paths []string, overlay string) *ConfigStore {
+	return &ConfigStore{Paths: paths, Overlay: overlay}
+}
+
+// Version hash of configuration files content at their last load
+func (s *ConfigStore) Version() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.version
+}
+
+// taskDefaults returns defaults applied to tasks added through API
+func (s *ConfigStore) taskDefaults() tasksDefaults {
+	s.Lock()
+	defer s.Unlock()
+	return s.defaults
+}
+
+func (s *ConfigStore) currentVersion() (string, error) {
+	files, err := ConfigFiles(s.Paths)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, file := range append(files, s.Overlay) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", file, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+func (s *ConfigStore) readOverlay() (overlayConfig, error) {
+	overlay := overlayConfig{}
+	data, err := ioutil.ReadFile(s.Overlay)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return overlay, nil
+		}
+		return overlay, err
+	}
+	err = yaml.UnmarshalStrict(data, &overlay)
+	return overlay, err
+}
+
+// Load reads and merges configuration files and applies overlay
+func (s *ConfigStore) Load() (TasksConfig, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.load()
+}
+
+func (s *ConfigStore) load() (TasksConfig, error) {
+	version, err := s.currentVersion()
+	if err != nil {
+		return TasksConfig{}, err
+	}
+	config, defaults, err := parseConfigFiles(s.Paths)
+	if err != nil {
+		return config, err
+	}
+	overlay := overlayConfig{}
+	if s.Overlay != "" {
+		if overlay, err = s.readOverlay(); err != nil {
+			return config, fmt.Errorf("%s: %s", s.Overlay, err)
+		}
+		// overlay tasks get defaults of the first configuration file and
+		// replace tasks of the same name before matrix expansion
+		tasks := TasksConfig{Defaults: defaults, Run: overlay.Run, Exec: overlay.Exec}
+		tasks.applyDefaults()
+		for name, task := range tasks.Run {
+			delete(config.Exec, name)
+			config.Run[name] = task
+		}
+		for name, task := range tasks.Exec {
+			delete(config.Run, name)
+			config.Exec[name] = task
+		}
+	}
+	if err := config.expand(); err != nil {
+		return config, err
+	}
+	config.removeTasks(overlay.Removed)
+	s.version = version
+	s.defaults = defaults
+	return config, nil
+}
+
+// removeTasks removes tasks by their names, or names of matrix tasks
+func (c *TasksConfig) removeTasks(names []string) {
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+	}
+	for name, task := range c.Run {
+		if removed[name] || removed[task.group] {
+			delete(c.Run, name)
+		}
+	}
+	for name, task := range c.Exec {
+		if removed[name] || removed[task.group] {
+			delete(c.Exec, name)
+		}
+	}
+}
+
+// Update modifies overlay file and returns reloaded configuration. Update
+// fails with ErrConfigChanged when configuration files were changed since
+// last load, or ErrVersionMismatch when loaded configuration doesn't match
+// expected version (if not empty).
+func (s *ConfigStore) Update(version string, update func(*overlayConfig)) (TasksConfig, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.Overlay == "" {
+		return TasksConfig{}, fmt.Errorf("Overlay file not configured")
+	}
+	current, err := s.currentVersion()
+	if err != nil {
+		return TasksConfig{}, err
+	}
+	if current != s.version {
+		return TasksConfig{}, ErrConfigChanged
+	}
+	if version != "" && version != s.version {
+		return TasksConfig{}, ErrVersionMismatch
+	}
+
+	original, err := ioutil.ReadFile(s.Overlay)
+	if err != nil && !os.IsNotExist(err) {
+		return TasksConfig{}, err
+	}
+	overlay, err := s.readOverlay()
+	if err != nil {
+		return TasksConfig{}, err
+	}
+	update(&overlay)
+	data, err := yaml.Marshal(overlay)
+	if err != nil {
+		return TasksConfig{}, err
+	}
+	if err := writeFile(s.Overlay, data); err != nil {
+		return TasksConfig{}, err
+	}
+	config, err := s.load()
+	if err != nil {
+		// restore previous state
+		if original != nil {
+			writeFile(s.Overlay, original)
+		} else {
+			os.Remove(s.Overlay)
+		}
+		s.load()
+		return config, InvalidConfigError{err}
+	}
+	return config, nil
+}
+
+// writeFile atomically replaces content of the file
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,124 @@
+package dcron
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testTasksFile = `
+defaults:
+  exec:
+    user: postgres
+exec:
+  vacuum:
+    service: db
+    command: vacuumdb
+`
+
+func taskNames(m *TaskManager) string {
+	var names []string
+	for _, task := range m.TasksList() {
+		names = append(names, task.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestConfigStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.yml")
+	if err := ioutil.WriteFile(path, []byte(testTasksFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore([]string{path}, DefaultOverlayPath([]string{path}))
+	config, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestTaskManager()
+	m.ConfigStore = store
+	if _, err := m.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(m)
+	request := func(method, url, body string) int {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	steps := []struct {
+		method, url, body string
+		status            int
+		tasks             string
+	}{
+		{"PUT", "/api/tasks/dump", "exec:\n  service: db\n  command: pg_dump {{ matrix.db }}\n  matrix:\n    db: [orders, users]\n",
+			http.StatusOK, "dump[orders],dump[users],vacuum"},
+		{"PUT", "/api/tasks/dump", "exec:\n  service: db\n  command: pg_dump {{ matrix.db }}\n  matrix:\n    db: [orders]\n",
+			http.StatusOK, "dump[orders],vacuum"},
+		{"DELETE", "/api/tasks/dump", "", http.StatusOK, "vacuum"},
+		{"DELETE", "/api/tasks/dump", "", http.StatusNotFound, "vacuum"},
+		{"DELETE", "/api/tasks/vacuum", "", http.StatusOK, ""},
+	}
+	for _, step := range steps {
+		if status := request(step.method, step.url, step.body); status != step.status {
+			t.Fatalf("%s %s: status %d, expected %d", step.method, step.url, status, step.status)
+		}
+		if names := taskNames(m); names != step.tasks {
+			t.Fatalf("%s %s: tasks %q, expected %q", step.method, step.url, names, step.tasks)
+		}
+		if step.method == "PUT" {
+			// overlay tasks get defaults of the configuration file
+			if task := m.Config.Exec["dump[orders]"]; task.User != "postgres" {
+				t.Errorf("defaults not applied to overlay task: %+v", task)
+			}
+		}
+	}
+}
+
+func TestValidateTaskDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.yml")
+	config := "defaults:\n  run:\n    image: alpine\n  exec:\n    service: db\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestTaskManager()
+	m.ConfigStore = NewConfigStore([]string{path}, DefaultOverlayPath([]string{path}))
+	if _, err := m.ConfigStore.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(m)
+	tests := []struct {
+		name, body string
+		status     int
+	}{
+		{"default image", "run:\n  command: date\n", http.StatusOK},
+		{"default service", "exec:\n  command: vacuumdb\n", http.StatusOK},
+		{"default service with matrix", "exec:\n  command: pg_dump {{ matrix.db }}\n  matrix:\n    db: [orders, users]\n", http.StatusOK},
+		{"missing command", "exec:\n  user: postgres\n", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest("PUT", "/api/tasks/task", strings.NewReader(test.body)))
+			if rec.Code != test.status {
+				t.Errorf("status %d, expected %d: %s", rec.Code, test.status, rec.Body)
+			}
+		})
+	}
+}
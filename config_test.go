@@ -0,0 +1,244 @@
+package dcron
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"run task", "run:\n  backup:\n    image: alpine\n    timeout: 30m\n", ""},
+		{"invalid duration", "run:\n  backup:\n    image: alpine\n    timeout: soon\n", `run.backup.timeout: invalid duration "soon"`},
+		{"exec task", "exec:\n  backup:\n    service: db\n    command: pg_dump\n    timeout: 30m\n", "exec.backup.timeout: timeout is supported only by run tasks"},
+		{"exec defaults", "defaults:\n  exec:\n    timeout: 30m\n", "defaults.exec.timeout: timeout is supported only by run tasks"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errors := ValidateConfig([]byte(test.config))
+			if test.err == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 || !strings.Contains(errors[0].Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, errors)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errors []string
+	}{
+		{"valid", "run:\n  backup:\n    image: alpine\n    schedule: '0 3 * * *'\n    stop_signal: SIGTERM\n", nil},
+		{"typo", "run:\n  backup:\n    image: alpine\n    shedule: '0 3 * * *'\n", []string{`line 4: run.backup: unknown field "shedule"`}},
+		{"unknown top-level field", "runs:\n  backup:\n    image: alpine\n", []string{`line 1: unknown field "runs"`}},
+		{"bad type", "run:\n  backup:\n    image: [alpine]\n", []string{"line 2: run.backup: image is required", "line 3: cannot unmarshal !!seq into string"}},
+		{"bad type and typo", "run:\n  backup:\n    image: alpine\n    command: [1, 2]\n    shedule: '0 3 * * *'\n", []string{
+			"line 4: run.backup.command: Unsupported item type: 1",
+			`line 5: run.backup: unknown field "shedule"`,
+		}},
+		{"bad environment", "exec:\n  backup:\n    service: db\n    command: pg_dump\n    environment: 1\n", []string{"line 5: exec.backup.environment: Unsupported type int"}},
+		{"duplicate names", "run:\n  backup:\n    image: alpine\nexec:\n  backup:\n    service: db\n    command: pg_dump\n", []string{`line 5: task "backup" is defined in both run: and exec:`}},
+		{"cron syntax", "run:\n  backup:\n    image: alpine\n    schedule: '0 25 * * *'\n", []string{"line 4: run.backup.schedule: end of range (25) above maximum (23)"}},
+		{"size", "logs:\n  max_total_size: 1XB\n", []string{"line 2: logs.max_total_size: "}},
+		{"size and typo", "logs:\n  max_total_size: 1XB\n  keep_run: 5\n", []string{"line 2: logs.max_total_size: ", "line 3: field keep_run not found"}},
+		{"task size", "run:\n  backup:\n    image: alpine\n    logs:\n      max_log_size: big\n", []string{"line 5: run.backup.logs.max_log_size: "}},
+		{"signal", "run:\n  backup:\n    image: alpine\n    stop_signal: SIGFOO\n", []string{`line 4: run.backup.stop_signal: invalid signal "SIGFOO"`}},
+		{"task name", "run:\n  daily report:\n    image: alpine\n", []string{`line 2: run.daily report: invalid task name "daily report"`}},
+		{"matrix value", "run:\n  backup:\n    image: alpine\n    matrix:\n      dir: [data, ../etc]\n", []string{`line 4: run.backup.matrix: invalid matrix value "../etc" of key "dir"`}},
+		{"volume", "run:\n  backup:\n    image: alpine\n    volumes: [data:backup]\n", []string{`run.backup.volumes: volume target "backup" must be an absolute path`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errors := ValidateConfig([]byte(test.config))
+			if len(errors) != len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for i, err := range errors {
+				if !strings.Contains(err.Error(), test.errors[i]) {
+					t.Errorf("expected error %q, got %q", test.errors[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSignal(t *testing.T) {
+	for _, signal := range []string{"SIGTERM", "TERM", "sighup", "9", "64"} {
+		if err := validateSignal(signal); err != nil {
+			t.Errorf("signal %q: unexpected error %v", signal, err)
+		}
+	}
+	for _, signal := range []string{"SIGFOO", "0", "65", "-1", ""} {
+		if err := validateSignal(signal); err == nil {
+			t.Errorf("signal %q: expected error", signal)
+		}
+	}
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "dcron-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConfigFilesOrder(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tasks.yml":                    "",
+		"conf.d/20-web.yaml":           "",
+		"conf.d/10-db.yml":             "",
+		"conf.d/.hidden.yml":           "",
+		"conf.d/tasks.overlay.yml":     "",
+		"conf.d/notes.txt":             "",
+		"conf.d/30-nested/ignored.yml": "",
+	})
+	defer os.RemoveAll(dir)
+	files, err := ConfigFiles([]string{filepath.Join(dir, "tasks.yml"), filepath.Join(dir, "conf.d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, file := range files {
+		files[i], _ = filepath.Rel(dir, file)
+	}
+	expected := "tasks.yml,conf.d/10-db.yml,conf.d/20-web.yaml"
+	if result := strings.Join(files, ","); result != expected {
+		t.Errorf("config files %q, expected %q", result, expected)
+	}
+}
+
+func TestParseConfigFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		tasks string
+		err   string
+	}{
+		{
+			name: "merged tasks",
+			files: map[string]string{
+				"10-db.yml":  "defaults:\n  exec:\n    service: db\nexec:\n  vacuum:\n    command: vacuumdb\n",
+				"20-web.yml": "run:\n  report:\n    image: alpine\nexec:\n  reload:\n    service: web\n    command: reload\n",
+			},
+			tasks: "exec:reload,exec:vacuum,run:report",
+		},
+		{
+			name: "duplicate task",
+			files: map[string]string{
+				"10-db.yml":  "exec:\n  backup:\n    service: db\n    command: pg_dump\n",
+				"20-web.yml": "run:\n  backup:\n    image: alpine\n",
+			},
+			err: `task "backup" is defined in both`,
+		},
+		{
+			name: "duplicate secret",
+			files: map[string]string{
+				"10-db.yml":  "secrets:\n  db:\n    environment: DB_PASSWORD\n",
+				"20-web.yml": "secrets:\n  db:\n    file: db_password\n",
+			},
+			err: `secret "db" is defined in both`,
+		},
+		{
+			name: "logs in two files",
+			files: map[string]string{
+				"10-db.yml":  "logs:\n  keep_runs: 10\n",
+				"20-web.yml": "logs:\n  keep_days: 7\n",
+			},
+			err: "logs retention is defined in both",
+		},
+		{
+			name: "notifications in two files",
+			files: map[string]string{
+				"10-db.yml":  "notifications:\n  - url: http://hooks/db\n",
+				"20-web.yml": "notifications:\n  - url: http://hooks/web\n",
+			},
+			err: "notifications are defined in both",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, test.files)
+			defer os.RemoveAll(dir)
+			config, defaults, err := parseConfigFiles([]string{dir})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tasks []string
+			for name := range config.Run {
+				tasks = append(tasks, "run:"+name)
+			}
+			for name := range config.Exec {
+				tasks = append(tasks, "exec:"+name)
+			}
+			sort.Strings(tasks)
+			if result := strings.Join(tasks, ","); result != test.tasks {
+				t.Errorf("tasks %q, expected %q", result, test.tasks)
+			}
+			// defaults of a file apply only to its tasks, defaults of the first file are returned for overlay tasks
+			if config.Exec["vacuum"].Service != "db" || config.Exec["reload"].Service != "web" || defaults.Exec.Service != "db" {
+				t.Errorf("unexpected defaults: %+v, %+v", config.Exec, defaults)
+			}
+		})
+	}
+}
+
+func TestDuplicateTaskNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"plain tasks", "run:\n  backup:\n    image: alpine\nexec:\n  backup:\n    service: db\n    command: pg_dump\n", `task "backup" is defined in both run: and exec:`},
+		{"matrix task", "run:\n  backup:\n    image: alpine\n    matrix:\n      db: [orders]\nexec:\n  backup[orders]:\n    service: db\n    command: pg_dump\n", `task "backup[orders]" is defined in both run: and exec:`},
+		{"different names", "run:\n  backup:\n    image: alpine\nexec:\n  vacuum:\n    service: db\n    command: vacuumdb\n", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, map[string]string{"tasks.yml": test.config})
+			defer os.RemoveAll(dir)
+			_, err := ParseConfigFile(filepath.Join(dir, "tasks.yml"))
+			errors := ValidateConfig([]byte(test.config))
+			if test.err == "" {
+				if err != nil || len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v, %v", err, errors)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("load: expected error %q, got %v", test.err, err)
+			}
+			if len(errors) != 1 || !strings.Contains(errors[0].Error(), test.err) {
+				t.Errorf("validate: expected error %q, got %v", test.err, errors)
+			}
+		})
+	}
+}
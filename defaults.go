@@ -0,0 +1,82 @@
+package dcron
+
+import (
+	"reflect"
+	"strings"
+)
+
+// applyDefaults merges defaults sections into tasks definitions
+func (c *TasksConfig) applyDefaults() {
+	for name, task := range c.Run {
+		mergeDefaults(reflect.ValueOf(&task).Elem(), reflect.ValueOf(c.Defaults.Run))
+		c.Run[name] = task
+	}
+	for name, task := range c.Exec {
+		mergeDefaults(reflect.ValueOf(&task).Elem(), reflect.ValueOf(c.Defaults.Exec))
+		c.Exec[name] = task
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// mergeDefaults sets empty fields of task to default values. Maps are merged
+// (task's values take precedence), volumes are merged by target path and
+// extension fields are not inherited.
+func mergeDefaults(task, defaults reflect.Value) {
+	for i := 0; i < task.NumField(); i++ {
+		if f := task.Type().Field(i); f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		field := task.Field(i)
+		value := defaults.Field(i)
+		name := task.Type().Field(i).Name
+		switch {
+		case name == "Extensions":
+		case field.Kind() == reflect.Struct:
+			mergeDefaults(field, value)
+		case name == "Volumes":
+			field.Set(reflect.ValueOf(mergeVolumes(value.Interface().([]string), field.Interface().([]string))))
+		case field.Kind() == reflect.Map && !value.IsNil():
+			merged := reflect.MakeMap(field.Type())
+			for _, key := range value.MapKeys() {
+				merged.SetMapIndex(key, value.MapIndex(key))
+			}
+			if !field.IsNil() {
+				for _, key := range field.MapKeys() {
+					merged.SetMapIndex(key, field.MapIndex(key))
+				}
+			}
+			field.Set(merged)
+		case isZero(field):
+			field.Set(value)
+		}
+	}
+}
+
+func volumeTarget(volume string) string {
+	parts := strings.Split(volume, ":")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return parts[0]
+}
+
+// mergeVolumes appends task's volumes to default volumes with different target
+func mergeVolumes(defaults, volumes []string) []string {
+	if len(defaults) == 0 {
+		return volumes
+	}
+	targets := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		targets[volumeTarget(volume)] = true
+	}
+	merged := make([]string, 0, len(defaults)+len(volumes))
+	for _, volume := range defaults {
+		if !targets[volumeTarget(volume)] {
+			merged = append(merged, volume)
+		}
+	}
+	return append(merged, volumes...)
+}
@@ -0,0 +1,57 @@
+package dcron
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	data := `
+defaults:
+  run:
+    image: alpine
+    schedule: "@daily"
+    environment: {TZ: UTC, LEVEL: info}
+    volumes: [data:/data, cache:/cache]
+    x-team: ops
+run:
+  backup:
+    command: backup
+    environment: {LEVEL: debug}
+    volumes: [backups:/data]
+  report:
+    image: python
+    schedule: "@hourly"
+`
+	var config TasksConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	config.applyDefaults()
+
+	backup := config.Run["backup"]
+	if backup.Image != "alpine" || backup.Schedule != "@daily" {
+		t.Errorf("defaults are not applied: %+v", backup)
+	}
+	if env := (envMap{"TZ": "UTC", "LEVEL": "debug"}); !reflect.DeepEqual(backup.Environment, env) {
+		t.Errorf("environment %v, expected %v", backup.Environment, env)
+	}
+	if volumes := []string{"cache:/cache", "backups:/data"}; !reflect.DeepEqual(backup.Volumes, volumes) {
+		t.Errorf("volumes %v, expected %v", backup.Volumes, volumes)
+	}
+	if len(backup.Extensions) != 0 {
+		t.Errorf("extension fields are inherited: %v", backup.Extensions)
+	}
+
+	report := config.Run["report"]
+	if report.Image != "python" || report.Schedule != "@hourly" {
+		t.Errorf("task values are overridden by defaults: %+v", report)
+	}
+	// defaults are copied, not shared between tasks
+	report.Environment["TZ"] = "CET"
+	if config.Defaults.Run.Environment["TZ"] != "UTC" {
+		t.Error("environment of defaults is shared with tasks")
+	}
+}
@@ -0,0 +1,119 @@
+package dcron
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Variables substitution in the same format as in Docker Compose files:
+// $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error}
+// and $$ for literal dollar sign.
+var interpolationRe = regexp.MustCompile(`\$(?:(\$)|\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*)|(\{))`)
+
+// interpolate substitutes variables in data (including YAML comments), unset
+// variables without default value are substituted by blank string with a warning
+func interpolate(path string, data []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	var errors ConfigErrors
+	warned := make(map[string]bool)
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		lines[i] = interpolationRe.ReplaceAllStringFunc(line, func(match string) string {
+			m := interpolationRe.FindStringSubmatch(match)
+			if m[1] != "" {
+				return "$"
+			}
+			if m[6] != "" {
+				errors = append(errors, ConfigError{i + 1, fmt.Sprintf("invalid interpolation format in %q", line)})
+				return match
+			}
+			name := m[2]
+			if name == "" {
+				name = m[5]
+			}
+			value, ok := lookup(name)
+			unset := !ok || (strings.HasPrefix(m[3], ":") && value == "")
+			switch strings.TrimPrefix(m[3], ":") {
+			case "-":
+				if unset {
+					return m[4]
+				}
+			case "?":
+				if unset {
+					msg := fmt.Sprintf("required variable %s is missing a value", name)
+					if m[4] != "" {
+						msg += ": " + m[4]
+					}
+					errors = append(errors, ConfigError{i + 1, msg})
+				}
+			default:
+				if !ok && !warned[name] {
+					warned[name] = true
+					LogWarn("Variable is not set, substituting a blank string (use $$ for literal $)",
+						Fields{"variable": name, "path": path, "line": i + 1})
+				}
+			}
+			return value
+		})
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// loadEnvFile reads variables from .env file, missing file is not an error
+func loadEnvFile(path string) (map[string]string, error) {
+	env := make(map[string]string)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return env, nil
+		}
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%s:%d: invalid variable definition", path, n)
+		}
+		value := strings.TrimSpace(parts[1])
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[strings.TrimSpace(parts[0])] = value
+	}
+	return env, scanner.Err()
+}
+
+// readConfigFile reads config file with interpolated environment variables,
+// values of variables defined in .env file next to the config file are used
+// when they are not set in dcron's environment
+func readConfigFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	env, err := loadEnvFile(filepath.Join(filepath.Dir(path), ".env"))
+	if err != nil {
+		return nil, err
+	}
+	return interpolate(path, data, func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := env[name]
+		return value, ok
+	})
+}
@@ -0,0 +1,89 @@
+package dcron
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOME": "/root", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	tests := []struct {
+		input, expected, err string
+	}{
+		{"echo $HOME ${HOME}", "echo /root /root", ""},
+		{"price: $$5", "price: $5", ""},
+		{"echo $$HOME", "echo $HOME", ""},
+		{"${UNSET:-default} ${EMPTY:-default} ${EMPTY-default}", "default default ", ""},
+		{"${UNSET}", "", ""},
+		{"${UNSET:?must be set}", "", "line 1: required variable UNSET is missing a value: must be set"},
+		{"a\n${EMPTY:?}", "", "line 2: required variable EMPTY is missing a value"},
+		{"${HOME", "", `line 1: invalid interpolation format in "${HOME"`},
+	}
+	for _, test := range tests {
+		result, err := interpolate("tasks.yml", []byte(test.input), lookup)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("interpolate(%q) error = %v, expected %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("interpolate(%q) unexpected error: %s", test.input, err)
+		} else if string(result) != test.expected {
+			t.Errorf("interpolate(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestInterpolateWarnsUnsetVariables(t *testing.T) {
+	var out bytes.Buffer
+	ConfigureLogging("text", "warn", &out)
+	defer ConfigureLogging("text", "info", os.Stderr)
+
+	lookup := func(string) (string, bool) { return "", false }
+	interpolate("tasks.yml", []byte("echo $UNSET_X ${UNSET_X}\necho ${UNSET_Y:-default}"), lookup)
+	log := out.String()
+	if strings.Count(log, "variable=UNSET_X") != 1 {
+		t.Errorf("expected single warning about UNSET_X, got:\n%s", log)
+	}
+	if strings.Contains(log, "UNSET_Y") {
+		t.Errorf("unexpected warning about variable with default value:\n%s", log)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".env")
+	data := "# comment\n\nexport A=1\nB = 'two words'\nC=\"x=y\"\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env, err := loadEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1", "B": "two words", "C": "x=y"}
+	for key, value := range expected {
+		if env[key] != value {
+			t.Errorf("%s = %q, expected %q", key, env[key], value)
+		}
+	}
+	if len(env) != len(expected) {
+		t.Errorf("unexpected variables: %v", env)
+	}
+	if env, err := loadEnvFile(filepath.Join(dir, "missing")); err != nil || len(env) != 0 {
+		t.Errorf("missing file: %v, %v", env, err)
+	}
+}
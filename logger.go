@@ -1,39 +1,335 @@
 package dcron
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type logMessage struct {
-	Log    string `json:"log"`
-	Stream string `json:"stream"`
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
 }
 
-func formatLog(w io.Writer, t string, p []byte) {
-	msg := logMessage{string(p), t}
+func formatLog(w io.Writer, t string, p []byte, at time.Time) {
+	msg := logMessage{string(p), t, at}
 	json.NewEncoder(w).Encode(msg)
 }
 
+// logWriter writes messages to the log file. When the output exceeds the
+// limit (if set), only its head and tail are kept, cut at line boundaries
+// (unless a single line is longer).
+type logWriter struct {
+	w        io.Writer
+	limit    int
+	written  int
+	tail     []logMessage
+	tailSize int
+	dropped  int
+	// tail starts in the middle of a line
+	cutLine bool
+	// incomplete last line of the head
+	pending     []logMessage
+	pendingSize int
+}
+
+// runeStart moves index back to the start of UTF-8 character
+func runeStart(p []byte, i int) int {
+	for i > 0 && i < len(p) && !utf8.RuneStart(p[i]) {
+		i--
+	}
+	return i
+}
+
+func (l *logWriter) write(stream string, p []byte, t time.Time) {
+	if l.limit <= 0 {
+		formatLog(l.w, stream, p, t)
+		return
+	}
+	if head := l.limit/2 - l.written; head > 0 {
+		if len(p) <= head {
+			l.written += len(p)
+			l.writeHead(stream, p, t, bytes.LastIndexByte(p, '\n')+1)
+			return
+		}
+		// head ends with the last line which fits
+		n := bytes.LastIndexByte(p[:head], '\n') + 1
+		if n == 0 && l.written == l.pendingSize {
+			// first line is longer than the head
+			n = runeStart(p, head)
+		}
+		l.writeHead(stream, p[:n], t, n)
+		p = p[n:]
+		// incomplete line of the head continues in the tail
+		l.tail, l.tailSize = l.pending, l.pendingSize
+		l.pending, l.pendingSize = nil, 0
+		l.written = l.limit / 2
+	}
+	tailLimit := l.limit - l.limit/2
+	l.tail = append(l.tail, logMessage{string(p), stream, t})
+	l.tailSize += len(p)
+	for l.tailSize > tailLimit {
+		first := l.tail[0].Log
+		excess := l.tailSize - tailLimit
+		if len(first) > excess {
+			excess = runeStart([]byte(first), excess)
+			if excess == 0 {
+				excess = len(first)
+			}
+		}
+		if excess < len(first) {
+			l.tail[0].Log = first[excess:]
+		} else {
+			excess = len(first)
+			l.tail = l.tail[1:]
+		}
+		if excess > 0 {
+			l.cutLine = first[excess-1] != '\n'
+		}
+		l.tailSize -= excess
+		l.dropped += excess
+	}
+}
+
+// writeHead writes complete lines of the head, the rest of p (incomplete
+// line) is kept pending until the line is completed
+func (l *logWriter) writeHead(stream string, p []byte, t time.Time, complete int) {
+	if complete > 0 {
+		for _, msg := range l.pending {
+			formatLog(l.w, msg.Stream, []byte(msg.Log), msg.Time)
+		}
+		l.pending, l.pendingSize = nil, 0
+		formatLog(l.w, stream, p[:complete], t)
+	}
+	if complete < len(p) {
+		l.pending = append(l.pending, logMessage{string(p[complete:]), stream, t})
+		l.pendingSize += len(p) - complete
+	}
+}
+
+// alignTail drops beginning of the tail up to the start of the next line,
+// unless the tail is the rest of a single line
+func (l *logWriter) alignTail() {
+	skipped := 0
+	for i, msg := range l.tail {
+		j := strings.IndexByte(msg.Log, '\n')
+		if j < 0 {
+			skipped += len(msg.Log)
+			continue
+		}
+		skipped += j + 1
+		if skipped == l.tailSize {
+			return
+		}
+		if j+1 < len(msg.Log) {
+			l.tail[i].Log = msg.Log[j+1:]
+			l.tail = l.tail[i:]
+		} else {
+			l.tail = l.tail[i+1:]
+		}
+		l.tailSize -= skipped
+		l.dropped += skipped
+		return
+	}
+}
+
+// Truncated reports whether some output was omitted
+func (l *logWriter) Truncated() bool {
+	return l.dropped > 0
+}
+
+// Flush writes incomplete last line of the head and buffered tail of the
+// output (after truncation marker)
+func (l *logWriter) Flush() {
+	for _, msg := range l.pending {
+		formatLog(l.w, msg.Stream, []byte(msg.Log), msg.Time)
+	}
+	l.pending, l.pendingSize = nil, 0
+	if l.cutLine {
+		l.alignTail()
+	}
+	if l.dropped > 0 && len(l.tail) > 0 {
+		marker := fmt.Sprintf("[CRON] Output truncated: %d bytes omitted\n", l.dropped)
+		formatLog(l.w, "stderr", []byte(marker), l.tail[0].Time)
+	}
+	encoder := json.NewEncoder(l.w)
+	for _, msg := range l.tail {
+		encoder.Encode(msg)
+	}
+	l.tail = nil
+	l.tailSize = 0
+	l.cutLine = false
+}
+
 type dualLogger struct {
-	Type   string
-	Stream io.Writer
-	Log    io.Writer
-	Size   int
+	Type     string
+	Stream   io.Writer
+	Log      *logWriter
+	Size     int
+	redactor *redactor
+	sinks    *runSinks
+	// end of output which can be beginning of a secret value
+	pending string
 }
 
 func (l *dualLogger) Write(p []byte) (n int, err error) {
-	formatLog(l.Log, l.Type, p)
-	n, err = l.Stream.Write(p)
-	l.Size += n
-	return
+	return l.writeAt(time.Now(), p)
+}
+
+func (l *dualLogger) writeAt(t time.Time, p []byte) (n int, err error) {
+	// secrets split between writes are redacted together with the following writes
+	text := l.pending + string(p)
+	n = l.redactor.redactedLength(text)
+	l.pending = text[n:]
+	if err = l.output(t, text[:n]); err != nil {
+		return 0, err
+	}
+	l.Size += len(p)
+	return len(p), nil
+}
+
+func (l *dualLogger) output(t time.Time, text string) error {
+	if text == "" {
+		return nil
+	}
+	data := []byte(l.redactor.Redact(text))
+	l.Log.write(l.Type, data, t)
+	l.sinks.write(l.Type, t, data)
+	_, err := l.Stream.Write(data)
+	return err
+}
+
+// flush writes remaining output held for redaction
+func (l *dualLogger) flush() error {
+	text := l.pending
+	l.pending = ""
+	return l.output(time.Now(), text)
 }
 
 type dockerLogger struct {
-	Log    io.Writer
+	Log    *logWriter
 	stdout *dualLogger
 	stderr *dualLogger
+	fields Fields
+	// directory for artifacts of the run
+	artifactsDir   string
+	artifactsLimit int64
+	redactor       *redactor
+	// values of secrets used by the run
+	secrets []string
+}
+
+func (l *dockerLogger) Fields() Fields {
+	return l.fields
+}
+
+func (l *dockerLogger) ArtifactsDir() string {
+	return l.artifactsDir
+}
+
+func (l *dockerLogger) ArtifactsLimit() int64 {
+	return l.artifactsLimit
+}
+
+func (l *dockerLogger) HoldSecrets(values ...string) {
+	l.redactor.Hold(values)
+	l.secrets = append(l.secrets, values...)
+}
+
+func (l *dockerLogger) releaseSecrets() {
+	l.redactor.Release(l.secrets)
+	l.secrets = nil
+}
+
+// mirror sets prefix of output lines mirrored to dcron's stdout/stderr,
+// or disables mirroring
+func (l *dockerLogger) mirror(prefix string, enabled bool) {
+	if !enabled {
+		l.stdout.Stream = ioutil.Discard
+		l.stderr.Stream = ioutil.Discard
+		return
+	}
+	l.stdout.Stream = &lineWriter{w: os.Stdout, prefix: prefix}
+	l.stderr.Stream = &lineWriter{w: os.Stderr, prefix: prefix}
+}
+
+// flushOutput writes output held for redaction of secrets split between writes
+func (l *dockerLogger) flushOutput() {
+	for _, logger := range []*dualLogger{l.stdout, l.stderr} {
+		if err := logger.flush(); err != nil {
+			LogError("Failed to write output", l.fields.With("error", err))
+		}
+	}
+}
+
+// flushMirror writes incomplete last lines of mirrored output
+func (l *dockerLogger) flushMirror() {
+	for _, logger := range []*dualLogger{l.stdout, l.stderr} {
+		if w, ok := logger.Stream.(*lineWriter); ok {
+			w.Flush()
+		}
+	}
+}
+
+// serializes writes of mirrored output of tasks running at the same time
+var mirrorMutex sync.Mutex
+
+// max length of buffered incomplete line of mirrored output
+const maxMirrorLine = 64 * 1024
+
+// lineWriter writes whole lines with a prefix, lines end with \n, \r\n or \r
+// (progress bars). Lines longer than maxMirrorLine are split.
+type lineWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	var out bytes.Buffer
+	start := 0
+	for i, c := range w.buf {
+		// \r at the end of buffer can be followed by \n in the next write
+		if c == '\n' || (c == '\r' && i+1 < len(w.buf) && w.buf[i+1] != '\n') {
+			out.WriteString(w.prefix)
+			out.Write(w.buf[start : i+1])
+			start = i + 1
+		}
+	}
+	if len(w.buf)-start > maxMirrorLine {
+		out.WriteString(w.prefix)
+		out.Write(w.buf[start:])
+		out.WriteByte('\n')
+		start = len(w.buf)
+	}
+	w.buf = append(w.buf[:0], w.buf[start:]...)
+	if out.Len() == 0 {
+		return len(p), nil
+	}
+	mirrorMutex.Lock()
+	defer mirrorMutex.Unlock()
+	if _, err := w.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes buffered incomplete line
+func (w *lineWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.Write([]byte("\n"))
+	return err
 }
 
 func (l *dockerLogger) StdoutWriter() io.Writer {
@@ -44,8 +340,86 @@ func (l *dockerLogger) StderrWriter() io.Writer {
 	return l.stderr
 }
 
-func newDockerLogger(out io.Writer) *dockerLogger {
-	stdoutLogger := &dualLogger{"stdout", os.Stdout, out, 0}
-	stderrLogger := &dualLogger{"stderr", os.Stderr, out, 0}
-	return &dockerLogger{Log: out, stdout: stdoutLogger, stderr: stderrLogger}
+// newDockerLogger creates logger writing to the log file (limited to
+// maxSize bytes of output, if not zero), log sinks and dcron's stdout/stderr
+func newDockerLogger(out io.Writer, r *redactor, maxSize int, sinks *runSinks) *dockerLogger {
+	logWriter := &logWriter{w: out, limit: maxSize}
+	stdoutLogger := &dualLogger{Type: "stdout", Stream: os.Stdout, Log: logWriter, redactor: r, sinks: sinks}
+	stderrLogger := &dualLogger{Type: "stderr", Stream: os.Stderr, Log: logWriter, redactor: r, sinks: sinks}
+	return &dockerLogger{Log: logWriter, stdout: stdoutLogger, stderr: stderrLogger, redactor: r}
+}
+
+// timedWriter writer of output with known time
+type timedWriter interface {
+	writeAt(t time.Time, p []byte) (int, error)
+}
+
+// timestampsWriter parses timestamps prefixed to lines of Docker logs
+// (ContainerLogsOptions.Timestamps) and writes lines without them
+type timestampsWriter struct {
+	w    io.Writer
+	last time.Time
+	// the last write ended inside of a line
+	inLine bool
+	// beginning of a line with incomplete timestamp
+	prefix []byte
+}
+
+func newTimestampsWriter(w io.Writer) *timestampsWriter {
+	return &timestampsWriter{w: w, last: time.Now()}
+}
+
+func (w *timestampsWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !w.inLine {
+			// lines and timestamps can be split between writes
+			line = append(w.prefix, line...)
+			w.prefix = nil
+			i := bytes.IndexByte(line, ' ')
+			if i < 0 && line[len(line)-1] != '\n' && len(line) < len(time.RFC3339Nano) {
+				// buffer of the writer can be reused
+				w.prefix = append([]byte(nil), line...)
+				continue
+			}
+			if i > 0 {
+				if t, err := time.Parse(time.RFC3339Nano, string(line[:i])); err == nil {
+					w.last = t
+					line = line[i+1:]
+				}
+			}
+			if len(line) == 0 {
+				// text of the line follows in next write
+				w.inLine = true
+				continue
+			}
+		}
+		w.inLine = line[len(line)-1] != '\n'
+		if err := w.write(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *timestampsWriter) write(line []byte) error {
+	if len(line) == 0 {
+		return nil
+	}
+	var err error
+	if tw, ok := w.w.(timedWriter); ok {
+		_, err = tw.writeAt(w.last, line)
+	} else {
+		_, err = w.w.Write(line)
+	}
+	return err
+}
+
+// Flush writes incomplete beginning of the last line
+func (w *timestampsWriter) Flush() error {
+	line := w.prefix
+	w.prefix = nil
+	return w.write(line)
 }
@@ -0,0 +1,191 @@
+package dcron
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"whole lines", []string{"a\nb\n"}, "[t#1] a\n[t#1] b\n"},
+		{"split lines", []string{"he", "llo\nwor", "ld\n"}, "[t#1] hello\n[t#1] world\n"},
+		{"incomplete last line", []string{"a\nb"}, "[t#1] a\n[t#1] b\n"},
+		{"empty line", []string{"\n"}, "[t#1] \n"},
+		{"carriage return", []string{"10%\r20%\r", "30%\n"}, "[t#1] 10%\r[t#1] 20%\r[t#1] 30%\n"},
+		{"crlf", []string{"a\r", "\nb\r\n"}, "[t#1] a\r\n[t#1] b\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &lineWriter{w: &out, prefix: "[t#1] "}
+			for _, data := range test.writes {
+				if n, err := w.Write([]byte(data)); err != nil || n != len(data) {
+					t.Fatalf("Write(%q) = %d, %v", data, n, err)
+				}
+			}
+			w.Flush()
+			if out.String() != test.expected {
+				t.Errorf("output %q, expected %q", out.String(), test.expected)
+			}
+		})
+	}
+}
+
+func TestLineWriterLongLine(t *testing.T) {
+	var out bytes.Buffer
+	w := &lineWriter{w: &out, prefix: "> "}
+	chunk := strings.Repeat("x", 1024)
+	for i := 0; i < 100; i++ {
+		w.Write([]byte(chunk))
+	}
+	if len(w.buf) > maxMirrorLine {
+		t.Errorf("buffered %d bytes, limit is %d", len(w.buf), maxMirrorLine)
+	}
+	w.Flush()
+	if n := strings.Count(out.String(), "x"); n != 100*1024 {
+		t.Errorf("written %d bytes of output, expected %d", n, 100*1024)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "> ") {
+			t.Errorf("line without prefix: %.20q", line)
+		}
+	}
+}
+
+func TestLogWriterTruncation(t *testing.T) {
+	lines := []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n", "line6\n"}
+	long := strings.Repeat("x", 30) + "\n"
+	tests := []struct {
+		name     string
+		limit    int
+		writes   []string
+		expected string
+	}{
+		{"within limit", 100, lines, strings.Join(lines, "")},
+		{"incomplete last line", 100, []string{"a\n", "b"}, "a\nb"},
+		{"single message", 20, []string{strings.Join(lines, "")},
+			"line1\n[CRON] Output truncated: 24 bytes omitted\nline6\n"},
+		{"message per line", 20, lines,
+			"line1\n[CRON] Output truncated: 24 bytes omitted\nline6\n"},
+		{"split lines", 20, []string{"line1\nli", "ne2\nline3\nline4\nli", "ne5\nline6\n"},
+			"line1\n[CRON] Output truncated: 24 bytes omitted\nline6\n"},
+		{"line longer than limit", 20, []string{long},
+			"xxxxxxxxxx[CRON] Output truncated: 11 bytes omitted\nxxxxxxxxx\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &logWriter{w: &out, limit: test.limit}
+			for _, data := range test.writes {
+				w.write("stdout", []byte(data), time.Now())
+			}
+			w.Flush()
+			var text strings.Builder
+			decoder := json.NewDecoder(&out)
+			for decoder.More() {
+				var msg logMessage
+				if err := decoder.Decode(&msg); err != nil {
+					t.Fatal(err)
+				}
+				text.WriteString(msg.Log)
+			}
+			if text.String() != test.expected {
+				t.Errorf("output %q, expected %q", text.String(), test.expected)
+			}
+			if w.Truncated() != (test.expected != strings.Join(test.writes, "")) {
+				t.Errorf("unexpected truncated flag %t", w.Truncated())
+			}
+		})
+	}
+}
+
+func TestDualLoggerSplitSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"whole secret", []string{"password: s3cret-pass\n"}, "password: ******\n"},
+		{"split secret", []string{"password: s3c", "ret-", "pass\n"}, "password: ******\n"},
+		{"secret at the end", []string{"password: s3cret", "-pass"}, "password: ******"},
+		{"beginning of secret", []string{"s3cr", "et\n"}, "s3cret\n"},
+		{"secret prefix in secret", []string{"s3cret-s3c", "ret-pass"}, "s3cret-******"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &redactor{}
+			r.Set([]string{"s3cret-pass"})
+			var out bytes.Buffer
+			l := &dualLogger{Type: "stdout", Stream: &out, Log: &logWriter{w: &bytes.Buffer{}}, redactor: r}
+			for _, data := range test.writes {
+				if n, err := l.Write([]byte(data)); err != nil || n != len(data) {
+					t.Fatalf("Write(%q) = %d, %v", data, n, err)
+				}
+				if strings.Contains(out.String(), "s3cret-pass") {
+					t.Fatalf("secret is not redacted: %q", out.String())
+				}
+			}
+			l.flush()
+			if out.String() != test.expected {
+				t.Errorf("output %q, expected %q", out.String(), test.expected)
+			}
+		})
+	}
+}
+
+// timedOutput records times of written output
+type timedOutput struct {
+	bytes.Buffer
+	times []string
+}
+
+func (o *timedOutput) writeAt(t time.Time, p []byte) (int, error) {
+	o.times = append(o.times, t.Format("15:04:05"))
+	return o.Write(p)
+}
+
+func TestTimestampsWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+		times    string
+	}{
+		{"whole lines", []string{"2020-05-01T10:00:00.1Z a\n2020-05-01T10:00:01.1Z b\n"}, "a\nb\n", "10:00:00,10:00:01"},
+		{"split line", []string{"2020-05-01T10:00:00.1Z hel", "lo\n2020-05-01T10:00:01.1Z b\n"}, "hello\nb\n", "10:00:00,10:00:00,10:00:01"},
+		{"split timestamp", []string{"2020-05-01T10:00:00.1Z a\n2020-05-01T10:", "00:01.1Z b\n"}, "a\nb\n", "10:00:00,10:00:01"},
+		{"split after timestamp", []string{"2020-05-01T10:00:00.1Z ", "a\n"}, "a\n", "10:00:00"},
+		{"continuation like timestamp", []string{"2020-05-01T10:00:00.1Z date: ", "2020-05-01T12:00:00Z x\n"}, "date: 2020-05-01T12:00:00Z x\n", "10:00:00,10:00:00"},
+		{"without timestamp", []string{"a\n", "b"}, "a\nb", "00:00:00,00:00:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &timedOutput{}
+			w := &timestampsWriter{w: out, last: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+			for _, data := range test.writes {
+				// writer's buffer is reused
+				buf := []byte(data)
+				if n, err := w.Write(buf); err != nil || n != len(data) {
+					t.Fatalf("Write(%q) = %d, %v", data, n, err)
+				}
+				for i := range buf {
+					buf[i] = '#'
+				}
+			}
+			w.Flush()
+			if out.String() != test.expected {
+				t.Errorf("output %q, expected %q", out.String(), test.expected)
+			}
+			if times := strings.Join(out.times, ","); times != test.times {
+				t.Errorf("times %s, expected %s", times, test.times)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package dcron
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Levels of dcron's log messages
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// Fields structured data of log messages
+type Fields map[string]interface{}
+
+// With returns copy of fields with added key and value
+func (f Fields) With(key string, value interface{}) Fields {
+	fields := make(Fields, len(f)+1)
+	for k, v := range f {
+		fields[k] = v
+	}
+	fields[key] = value
+	return fields
+}
+
+type eventLogger struct {
+	sync.Mutex
+	out   io.Writer
+	json  bool
+	level int
+}
+
+var events = &eventLogger{out: os.Stderr, level: LevelInfo}
+
+// ConfigureLogging sets format (text or json), minimal level (debug, info,
+// warn or error) and output of dcron's log messages. Messages of standard
+// log package are logged as info messages.
+func ConfigureLogging(format, level string, out io.Writer) error {
+	events.Lock()
+	defer events.Unlock()
+	switch format {
+	case "", "text":
+		events.json = false
+	case "json":
+		events.json = true
+	default:
+		return fmt.Errorf("Invalid log format %q (text or json)", format)
+	}
+	if level != "" {
+		found := false
+		for i, name := range levelNames {
+			if strings.ToLower(level) == name {
+				events.level = i
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Invalid log level %q (debug, info, warn or error)", level)
+		}
+	}
+	events.out = out
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+	return nil
+}
+
+// stdLogWriter writes messages of standard log package as info messages
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	logEvent(LevelInfo, strings.TrimSpace(string(p)), nil)
+	return len(p), nil
+}
+
+func formatValue(value interface{}, json bool) interface{} {
+	switch v := value.(type) {
+	case time.Duration:
+		if json {
+			return v.Seconds()
+		}
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+func logEvent(level int, msg string, fields Fields) {
+	events.Lock()
+	defer events.Unlock()
+	if level < events.level {
+		return
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	if events.json {
+		entry := make(map[string]interface{}, len(fields)+3)
+		for key, value := range fields {
+			entry[key] = formatValue(value, true)
+		}
+		entry["time"] = now.Format(time.RFC3339Nano)
+		entry["level"] = levelNames[level]
+		entry["msg"] = msg
+		json.NewEncoder(&buf).Encode(entry)
+	} else {
+		fmt.Fprintf(&buf, "%s [%s] %s", now.Format("2006/01/02 15:04:05"), strings.ToUpper(levelNames[level]), msg)
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := fmt.Sprint(formatValue(fields[key], false))
+			if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(&buf, " %s=%s", key, value)
+		}
+		buf.WriteByte('\n')
+	}
+	events.out.Write(buf.Bytes())
+}
+
+// LogDebug logs debug message
+func LogDebug(msg string, fields Fields) {
+	logEvent(LevelDebug, msg, fields)
+}
+
+// LogInfo logs info message
+func LogInfo(msg string, fields Fields) {
+	logEvent(LevelInfo, msg, fields)
+}
+
+// LogWarn logs warning message
+func LogWarn(msg string, fields Fields) {
+	logEvent(LevelWarn, msg, fields)
+}
+
+// LogError logs error message
+func LogError(msg string, fields Fields) {
+	logEvent(LevelError, msg, fields)
+}
+
+// LogFatal logs error message and exits
+func LogFatal(msg string, fields Fields) {
+	logEvent(LevelError, msg, fields)
+	os.Exit(1)
+}
+
+// requestLogger HTTP middleware logging served requests
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			fields := Fields{
+				"method":   r.Method,
+				"path":     r.URL.Path,
+				"status":   ww.Status(),
+				"bytes":    ww.BytesWritten(),
+				"remote":   r.RemoteAddr,
+				"duration": time.Since(start),
+			}
+			if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+				fields["request_id"] = reqID
+			}
+			level := LevelInfo
+			if ww.Status() >= 500 {
+				level = LevelError
+			}
+			logEvent(level, "HTTP request", fields)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
@@ -0,0 +1,117 @@
+package dcron
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs configures logging into returned buffer, until restore is called
+func captureLogs(t *testing.T, format, level string) (*bytes.Buffer, func()) {
+	var buf bytes.Buffer
+	if err := ConfigureLogging(format, level, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, func() {
+		ConfigureLogging("text", "info", os.Stderr)
+	}
+}
+
+func TestLoggingJSON(t *testing.T) {
+	buf, restore := captureLogs(t, "json", "debug")
+	defer restore()
+	LogInfo("Task finished", Fields{"task": "backup", "run_id": 3, "duration": 1500 * time.Millisecond, "error": errors.New("exit status 1")})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf, err)
+	}
+	expected := map[string]interface{}{
+		"level": "info", "msg": "Task finished", "task": "backup",
+		"run_id": 3.0, "duration": 1.5, "error": "exit status 1",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("%s = %v, expected %v", key, entry[key], value)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, entry["time"].(string)); err != nil {
+		t.Errorf("invalid time: %v", err)
+	}
+}
+
+func TestLoggingText(t *testing.T) {
+	buf, restore := captureLogs(t, "text", "debug")
+	defer restore()
+	LogWarn("Task finished with non-zero status", Fields{"task": "backup", "status": 2, "duration": 1500 * time.Millisecond, "error": "no space left"})
+
+	line := buf.String()
+	expected := ` [WARN] Task finished with non-zero status duration=1.5s error="no space left" status=2 task=backup` + "\n"
+	if !strings.HasSuffix(line, expected) {
+		t.Errorf("log line %q, expected to end with %q", line, expected)
+	}
+	if _, err := time.Parse("2006/01/02 15:04:05", line[:19]); err != nil {
+		t.Errorf("invalid time: %v", err)
+	}
+}
+
+func TestLoggingLevel(t *testing.T) {
+	buf, restore := captureLogs(t, "json", "warn")
+	defer restore()
+	LogDebug("debug", nil)
+	LogInfo("info", nil)
+	LogWarn("warn", nil)
+	LogError("error", nil)
+	// messages of standard log package are info messages
+	log.Print("standard")
+
+	var levels []string
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var entry map[string]interface{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		levels = append(levels, entry["level"].(string))
+	}
+	if result := strings.Join(levels, ","); result != "warn,error" {
+		t.Errorf("logged levels %q, expected warn,error", result)
+	}
+}
+
+func TestConfigureLoggingErrors(t *testing.T) {
+	defer ConfigureLogging("text", "info", os.Stderr)
+	if err := ConfigureLogging("xml", "", os.Stderr); err == nil {
+		t.Error("invalid format is accepted")
+	}
+	if err := ConfigureLogging("json", "verbose", os.Stderr); err == nil {
+		t.Error("invalid level is accepted")
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	buf, restore := captureLogs(t, "json", "info")
+	defer restore()
+	handler := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/run/backup", nil))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf, err)
+	}
+	if entry["level"] != "error" || entry["method"] != "POST" || entry["path"] != "/api/run/backup" || entry["status"] != 500.0 {
+		t.Errorf("unexpected request log entry: %v", entry)
+	}
+	if _, ok := entry["duration"].(float64); !ok {
+		t.Errorf("duration missing: %v", entry)
+	}
+}
@@ -0,0 +1,289 @@
+package dcron
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logQuery filters and output format of run logs (logs API query parameters)
+type logQuery struct {
+	Format string
+	Stream string
+	Tail   int
+	Since  time.Time
+	Until  time.Time
+}
+
+var logContentTypes = map[string]string{
+	"text":   "text/plain; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"html":   "text/html; charset=utf-8",
+}
+
+func parseLogQuery(values url.Values) (logQuery, error) {
+	q := logQuery{
+		Format: values.Get("format"),
+		Stream: values.Get("stream"),
+	}
+	if _, ok := logContentTypes[q.Format]; q.Format != "" && !ok {
+		return q, fmt.Errorf("Invalid format (text, ndjson or html)")
+	}
+	if q.Stream != "" && q.Stream != "stdout" && q.Stream != "stderr" {
+		return q, fmt.Errorf("Invalid stream (stdout or stderr)")
+	}
+	if value := values.Get("tail"); value != "" {
+		tail, err := strconv.Atoi(value)
+		if err != nil || tail < 0 {
+			return q, fmt.Errorf("Invalid tail parameter")
+		}
+		q.Tail = tail
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if value := values.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return q, fmt.Errorf("Invalid %s parameter (RFC3339 time expected)", name)
+			}
+			*t = parsed
+		}
+	}
+	return q, nil
+}
+
+// Raw reports whether the log file can be served as it is
+func (q logQuery) Raw() bool {
+	return (q.Format == "" || q.Format == "ndjson") && q.Stream == "" && q.Tail == 0 &&
+		q.Since.IsZero() && q.Until.IsZero()
+}
+
+func (q logQuery) match(msg logMessage) bool {
+	if q.Stream != "" && msg.Stream != q.Stream {
+		return false
+	}
+	if !q.Since.IsZero() && msg.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && msg.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// filterLogs calls fn for log messages read from r matching the query.
+// Tail counts lines, messages with multiple lines are cut to the requested lines.
+func filterLogs(r io.Reader, q logQuery, fn func(logMessage) error) error {
+	decoder := json.NewDecoder(r)
+	var tail []logMessage
+	// number of complete lines in tail messages
+	lines := 0
+	// incomplete last line is counted too
+	tailLines := func(lines int) int {
+		if last := tail[len(tail)-1].Log; last != "" && !strings.HasSuffix(last, "\n") {
+			return lines + 1
+		}
+		return lines
+	}
+	for {
+		var msg logMessage
+		if err := decoder.Decode(&msg); err == io.EOF || err == io.ErrUnexpectedEOF {
+			// incomplete last message of running task's log
+			break
+		} else if err != nil {
+			return err
+		}
+		if !q.match(msg) {
+			continue
+		}
+		if q.Tail == 0 {
+			if err := fn(msg); err != nil {
+				return err
+			}
+			continue
+		}
+		tail = append(tail, msg)
+		lines += strings.Count(msg.Log, "\n")
+		for len(tail) > 1 {
+			first := strings.Count(tail[0].Log, "\n")
+			needed := q.Tail
+			if !strings.HasSuffix(tail[0].Log, "\n") {
+				// the first message ends with beginning of the next line
+				needed++
+			}
+			if tailLines(lines-first) < needed {
+				break
+			}
+			tail = tail[1:]
+			lines -= first
+		}
+	}
+	if len(tail) > 0 {
+		// drop leading lines of the first message
+		text := tail[0].Log
+		for excess := tailLines(lines) - q.Tail; excess > 0; excess-- {
+			text = text[strings.IndexByte(text, '\n')+1:]
+		}
+		tail[0].Log = text
+	}
+	for _, msg := range tail {
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLogs writes log messages read from r matching the query in requested format
+func writeLogs(w io.Writer, r io.Reader, q logQuery) error {
+	var fn func(logMessage) error
+	switch q.Format {
+	case "text":
+		fn = func(msg logMessage) error {
+			_, err := io.WriteString(w, msg.Log)
+			return err
+		}
+	case "html":
+		converters := map[string]*ansiConverter{}
+		fn = func(msg logMessage) error {
+			converter, ok := converters[msg.Stream]
+			if !ok {
+				converter = &ansiConverter{}
+				converters[msg.Stream] = converter
+			}
+			_, err := fmt.Fprintf(w, `<span class="%s" title="%s">%s</span>`, html.EscapeString(msg.Stream),
+				msg.Time.Format(time.RFC3339Nano), converter.Convert(msg.Log))
+			return err
+		}
+		io.WriteString(w, `<pre class="logs">`)
+		defer io.WriteString(w, "</pre>\n")
+	default:
+		encoder := json.NewEncoder(w)
+		fn = func(msg logMessage) error {
+			return encoder.Encode(msg)
+		}
+	}
+	return filterLogs(r, q, fn)
+}
+
+// max length of matched lines in search results
+const maxSnippetLength = 300
+
+// logSearchQuery parameters of logs search
+type logSearchQuery struct {
+	Text       string
+	Task       string
+	Since      time.Time
+	Limit      int
+	MaxMatches int
+}
+
+type logSearchMatch struct {
+	Line   string    `json:"line"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+type logSearchResult struct {
+	Task    string           `json:"task"`
+	ID      int              `json:"id"`
+	Time    time.Time        `json:"time"`
+	Count   int              `json:"count"`
+	Matches []logSearchMatch `json:"matches"`
+}
+
+func snippet(line string, index int) string {
+	if len(line) <= maxSnippetLength {
+		return line
+	}
+	start := index - maxSnippetLength/2
+	if start < 0 {
+		start = 0
+	}
+	if start+maxSnippetLength > len(line) {
+		start = len(line) - maxSnippetLength
+	}
+	data := []byte(line)
+	return string(data[runeStart(data, start):runeStart(data, start+maxSnippetLength)])
+}
+
+// searchLog returns lines of the log containing (case insensitive) text
+func searchLog(r io.Reader, text string, maxMatches int) (int, []logSearchMatch, error) {
+	text = strings.ToLower(text)
+	count := 0
+	var matches []logSearchMatch
+	err := filterLogs(r, logQuery{}, func(msg logMessage) error {
+		for _, line := range strings.Split(strings.TrimSuffix(msg.Log, "\n"), "\n") {
+			index := strings.Index(strings.ToLower(line), text)
+			if index < 0 {
+				continue
+			}
+			count++
+			if len(matches) < maxMatches {
+				matches = append(matches, logSearchMatch{snippet(line, index), msg.Stream, msg.Time})
+			}
+		}
+		return nil
+	})
+	return count, matches, err
+}
+
+// SearchLogs finds runs with logs containing the query text (newest runs first)
+func (m *TaskManager) SearchLogs(q logSearchQuery) ([]logSearchResult, error) {
+	files, err := m.logFiles(m.statsSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	type run struct {
+		task string
+		file *logFile
+	}
+	var runs []run
+	for task, taskFiles := range files {
+		if q.Task != "" && task != q.Task {
+			continue
+		}
+		for _, file := range taskFiles {
+			if q.Since.IsZero() || !file.modTime.Before(q.Since) {
+				runs = append(runs, run{task, file})
+			}
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].file.time.After(runs[j].file.time)
+	})
+
+	results := make([]logSearchResult, 0)
+	for _, run := range runs {
+		f, compressed, err := m.OpenLog(run.task, run.file.id)
+		if err != nil {
+			// removed by retention
+			continue
+		}
+		var reader io.Reader = f
+		if compressed {
+			if reader, err = gzip.NewReader(f); err != nil {
+				f.Close()
+				continue
+			}
+		}
+		count, matches, err := searchLog(reader, q.Text, q.MaxMatches)
+		f.Close()
+		if err != nil {
+			LogWarn("Failed to search log file", Fields{"task": run.task, "run_id": run.file.id, "error": err})
+		}
+		if count > 0 {
+			results = append(results, logSearchResult{run.task, run.file.id, run.file.time, count, matches})
+			if len(results) >= q.Limit {
+				break
+			}
+		}
+	}
+	return results, nil
+}
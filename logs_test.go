@@ -0,0 +1,74 @@
+package dcron
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", 500) + "needle" + strings.Repeat("b", 500)
+	tests := []struct {
+		name  string
+		line  string
+		index int
+		start string
+	}{
+		{"short line", "connection refused", 11, "connection refused"},
+		{"match in the middle", long, 500, strings.Repeat("a", maxSnippetLength/2) + "needle"},
+		{"match at the start", "needle" + long, 0, "needle"},
+		{"match at the end", long + "needle", len(long), strings.Repeat("b", maxSnippetLength-6) + "needle"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := snippet(test.line, test.index)
+			if !strings.HasPrefix(s, test.start) || len(s) > maxSnippetLength {
+				t.Errorf("snippet %.40q... (%d bytes), expected to start with %.40q", s, len(s), test.start)
+			}
+		})
+	}
+
+	// multi-byte characters are not split
+	line := strings.Repeat("ž", 400)
+	if s := snippet(line, 400); !utf8.ValidString(s) || len(s) > maxSnippetLength {
+		t.Errorf("invalid snippet %q", s)
+	}
+}
+
+func TestFilterLogsTail(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		tail     int
+		expected string
+	}{
+		{"single line messages", []string{"a\n", "b\n", "c\n"}, 2, "b\nc\n"},
+		{"multi-line message", []string{"a\n", "b\nc\nd\n"}, 2, "c\nd\n"},
+		{"multi-line first message", []string{"a\nb\nc\n", "d\n"}, 3, "b\nc\nd\n"},
+		{"line split between messages", []string{"a\nb", "c\n", "d\n"}, 2, "bc\nd\n"},
+		{"incomplete last line", []string{"a\nb\n", "c"}, 2, "b\nc"},
+		{"fewer lines", []string{"a\nb\n"}, 5, "a\nb\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var data bytes.Buffer
+			encoder := json.NewEncoder(&data)
+			for _, text := range test.messages {
+				encoder.Encode(logMessage{Log: text, Stream: "stdout"})
+			}
+			var out strings.Builder
+			err := filterLogs(&data, logQuery{Tail: test.tail}, func(msg logMessage) error {
+				out.WriteString(msg.Log)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.expected {
+				t.Errorf("output %q, expected %q", out.String(), test.expected)
+			}
+		})
+	}
+}
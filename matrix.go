@@ -0,0 +1,198 @@
+package dcron
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// matrix values placeholder, e.g. {{ matrix.db }}
+var matrixVarRe = regexp.MustCompile(`\{\{\s*matrix\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// task names are used in names of log files and in URLs, names of tasks
+// expanded from matrix (e.g. db-backup[orders]) are allowed too
+var (
+	taskNameRe    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*(\[[A-Za-z0-9_.-]+(,[A-Za-z0-9_.-]+)*\])?$`)
+	matrixValueRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+func validateTaskName(name string) error {
+	if !taskNameRe.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid task name %q (letters, digits, '_', '.' and '-' are allowed)", name)
+	}
+	return nil
+}
+
+// matrixCombinations returns all combinations of matrix values, ordered
+// by sorted keys and values in the order of their definition
+func matrixCombinations(matrix map[string][]string) []map[string]string {
+	keys := make([]string, 0, len(matrix))
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		next := make([]map[string]string, 0, len(combinations)*len(matrix[key]))
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				values := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					values[k] = v
+				}
+				values[key] = value
+				next = append(next, values)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// matrixTaskName name of the task expanded from matrix, e.g. db-backup[orders]
+func matrixTaskName(name string, values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = values[key]
+	}
+	return fmt.Sprintf("%s[%s]", name, strings.Join(parts, ","))
+}
+
+func substituteMatrix(text string, values map[string]string) string {
+	return matrixVarRe.ReplaceAllStringFunc(text, func(match string) string {
+		return values[matrixVarRe.FindStringSubmatch(match)[1]]
+	})
+}
+
+// validateMatrix checks matrix definition and placeholders used in given texts
+func validateMatrix(matrix map[string][]string, texts ...string) error {
+	for key, values := range matrix {
+		if len(values) == 0 {
+			return fmt.Errorf("matrix key %q has no values", key)
+		}
+		// values are part of expanded task names
+		for _, value := range values {
+			if !matrixValueRe.MatchString(value) || strings.Contains(value, "..") {
+				return fmt.Errorf("invalid matrix value %q of key %q (letters, digits, '_', '.' and '-' are allowed)", value, key)
+			}
+		}
+	}
+	for _, text := range texts {
+		for _, m := range matrixVarRe.FindAllStringSubmatch(text, -1) {
+			if _, ok := matrix[m[1]]; !ok {
+				return fmt.Errorf("undefined matrix variable %q", m[1])
+			}
+		}
+	}
+	return nil
+}
+
+func (t *baseTask) matrixTexts() []string {
+	texts := append([]string{t.Description}, t.Command...)
+	for _, value := range t.Environment {
+		texts = append(texts, value)
+	}
+	return texts
+}
+
+// expand returns copy of the task with substituted matrix values
+func (t baseTask) expand(group string, values map[string]string) baseTask {
+	t.Matrix = nil
+	t.group = group
+	t.matrixValues = values
+	t.Description = substituteMatrix(t.Description, values)
+	if t.Command != nil {
+		command := make(strSlice, len(t.Command))
+		for i, arg := range t.Command {
+			command[i] = substituteMatrix(arg, values)
+		}
+		t.Command = command
+	}
+	if t.Environment != nil {
+		env := make(envMap, len(t.Environment))
+		for key, value := range t.Environment {
+			env[key] = substituteMatrix(value, values)
+		}
+		t.Environment = env
+	}
+	return t
+}
+
+// expandMatrix replaces tasks with matrix by the tasks expanded for all
+// combinations of matrix values
+func (c *TasksConfig) expandMatrix() error {
+	var errors ConfigErrors
+	// expanded names must not collide with names of other tasks of the same
+	// kind (collisions between run and exec tasks are checked by checkTaskNames)
+	define := func(names map[string]bool, name string) bool {
+		if names[name] {
+			errors = append(errors, fmt.Errorf("task %q is defined more than once", name))
+			return false
+		}
+		names[name] = true
+		return true
+	}
+	run := make(map[string]runTask, len(c.Run))
+	runNames := make(map[string]bool)
+	for name, task := range c.Run {
+		if err := validateTaskName(name); err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if len(task.Matrix) == 0 {
+			if define(runNames, name) {
+				run[name] = task
+			}
+			continue
+		}
+		if err := validateMatrix(task.Matrix, task.matrixTexts()...); err != nil {
+			errors = append(errors, fmt.Errorf("run.%s: %s", name, err))
+			continue
+		}
+		for _, values := range matrixCombinations(task.Matrix) {
+			expanded := task
+			expanded.baseTask = task.expand(name, values)
+			if taskName := matrixTaskName(name, values); define(runNames, taskName) {
+				run[taskName] = expanded
+			}
+		}
+	}
+	exec := make(map[string]execTask, len(c.Exec))
+	execNames := make(map[string]bool)
+	for name, task := range c.Exec {
+		if err := validateTaskName(name); err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if len(task.Matrix) == 0 {
+			if define(execNames, name) {
+				exec[name] = task
+			}
+			continue
+		}
+		if err := validateMatrix(task.Matrix, append(task.matrixTexts(), task.Service)...); err != nil {
+			errors = append(errors, fmt.Errorf("exec.%s: %s", name, err))
+			continue
+		}
+		for _, values := range matrixCombinations(task.Matrix) {
+			expanded := task
+			expanded.baseTask = task.expand(name, values)
+			expanded.Service = substituteMatrix(task.Service, values)
+			if taskName := matrixTaskName(name, values); define(execNames, taskName) {
+				exec[taskName] = expanded
+			}
+		}
+	}
+	c.Run = run
+	c.Exec = exec
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package dcron
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	combinations := matrixCombinations(map[string][]string{"db": {"orders", "users"}, "env": {"prod", "stage"}})
+	var names []string
+	for _, values := range combinations {
+		names = append(names, matrixTaskName("backup", values))
+	}
+	expected := []string{"backup[orders,prod]", "backup[orders,stage]", "backup[users,prod]", "backup[users,stage]"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("combinations %v, expected %v", names, expected)
+	}
+}
+
+func TestExpandMatrix(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TasksConfig
+		tasks  []string
+		err    string
+	}{
+		{
+			name: "run and exec tasks",
+			config: TasksConfig{
+				Run: map[string]runTask{"report": {
+					baseTask: baseTask{Command: strSlice{"report", "{{ matrix.db }}"}, Matrix: map[string][]string{"db": {"orders", "users"}}},
+				}},
+				Exec: map[string]execTask{"vacuum": {
+					baseTask: baseTask{Command: strSlice{"vacuum"}, Matrix: map[string][]string{"db": {"orders"}}},
+					Service:  "{{ matrix.db }}-db",
+				}},
+			},
+			tasks: []string{"report[orders]", "report[users]", "vacuum[orders]"},
+		},
+		{
+			name: "undefined variable",
+			config: TasksConfig{Run: map[string]runTask{"report": {
+				baseTask: baseTask{Command: strSlice{"{{ matrix.env }}"}, Matrix: map[string][]string{"db": {"orders"}}},
+			}}},
+			err: `run.report: undefined matrix variable "env"`,
+		},
+		{
+			name: "empty values",
+			config: TasksConfig{Run: map[string]runTask{"report": {
+				baseTask: baseTask{Matrix: map[string][]string{"db": {}}},
+			}}},
+			err: `run.report: matrix key "db" has no values`,
+		},
+		{
+			name: "name collision",
+			config: TasksConfig{Run: map[string]runTask{
+				"report":         {baseTask: baseTask{Matrix: map[string][]string{"db": {"orders"}}}},
+				"report[orders]": {},
+			}},
+			err: `task "report[orders]" is defined more than once`,
+		},
+		{
+			name: "path in value",
+			config: TasksConfig{Run: map[string]runTask{"report": {
+				baseTask: baseTask{Matrix: map[string][]string{"dir": {"../etc"}}},
+			}}},
+			err: `run.report: invalid matrix value "../etc" of key "dir"`,
+		},
+		{
+			name: "space in value",
+			config: TasksConfig{Exec: map[string]execTask{"report": {
+				baseTask: baseTask{Matrix: map[string][]string{"db": {"my db"}}},
+			}}},
+			err: `exec.report: invalid matrix value "my db" of key "db"`,
+		},
+		{
+			name:   "invalid task name",
+			config: TasksConfig{Run: map[string]runTask{"daily/report": {}}},
+			err:    `invalid task name "daily/report"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.expandMatrix()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tasks []string
+			for name := range test.config.Run {
+				tasks = append(tasks, name)
+			}
+			for name := range test.config.Exec {
+				tasks = append(tasks, name)
+			}
+			sort.Strings(tasks)
+			if !reflect.DeepEqual(tasks, test.tasks) {
+				t.Errorf("tasks %v, expected %v", tasks, test.tasks)
+			}
+		})
+	}
+}
+
+func TestExpandMatrixSubstitution(t *testing.T) {
+	task := execTask{Service: "{{ matrix.db }}-db"}
+	task.Description = "Vacuum of {{matrix.db}}"
+	task.Command = strSlice{"vacuumdb", "{{ matrix.db }}"}
+	task.Environment = envMap{"DB": "{{ matrix.db }}"}
+	task.Matrix = map[string][]string{"db": {"orders"}}
+	config := TasksConfig{Exec: map[string]execTask{"vacuum": task}}
+	if err := config.expandMatrix(); err != nil {
+		t.Fatal(err)
+	}
+	expanded := config.Exec["vacuum[orders]"]
+	if expanded.Service != "orders-db" || expanded.Description != "Vacuum of orders" ||
+		!reflect.DeepEqual(expanded.Command, strSlice{"vacuumdb", "orders"}) || expanded.Environment["DB"] != "orders" {
+		t.Errorf("unexpected expanded task: %+v", expanded)
+	}
+	if expanded.group != "vacuum" || expanded.matrixValues["db"] != "orders" || expanded.Matrix != nil {
+		t.Errorf("unexpected matrix of expanded task: %+v", expanded.baseTask)
+	}
+	// definition of the matrix task is not modified
+	if task.Command[1] != "{{ matrix.db }}" || task.Environment["DB"] != "{{ matrix.db }}" {
+		t.Errorf("matrix task modified: %+v", task)
+	}
+}
@@ -0,0 +1,218 @@
+package dcron
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// task events of webhook notifications
+const (
+	eventStart   = "start"
+	eventSuccess = "success"
+	eventFailure = "failure"
+	eventTimeout = "timeout"
+)
+
+// events notified when webhook has no filter
+var defaultEvents = []string{eventFailure, eventTimeout}
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 5
+	webhookBackoff  = 2 * time.Second
+	// log excerpt of finished runs (last lines of output)
+	excerptLines = 20
+	excerptSize  = 4096
+)
+
+// webhookConfig webhook called on task events (failure and timeout by default)
+type webhookConfig struct {
+	URL     string            `yaml:"url"`
+	On      []string          `yaml:"on,flow,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+func (c webhookConfig) validate() error {
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid URL %q", c.URL)
+	}
+	for _, event := range c.On {
+		switch event {
+		case eventStart, eventSuccess, eventFailure, eventTimeout:
+		default:
+			return fmt.Errorf("invalid event %q (start, success, failure or timeout)", event)
+		}
+	}
+	return nil
+}
+
+func (c webhookConfig) accepts(event string) bool {
+	events := c.On
+	if len(events) == 0 {
+		events = defaultEvents
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func validateNotifications(hooks []webhookConfig) []fieldError {
+	var errors []fieldError
+	for i, hook := range hooks {
+		if err := hook.validate(); err != nil {
+			errors = append(errors, fieldError{fmt.Sprintf("notifications.%d", i), err.Error()})
+		}
+	}
+	return errors
+}
+
+// notification JSON payload of webhooks
+type notification struct {
+	Event     string    `json:"event"`
+	Task      string    `json:"task"`
+	RunID     int       `json:"run_id"`
+	StartTime time.Time `json:"start_time"`
+	Status    *int      `json:"status,omitempty"`
+	Duration  float64   `json:"duration,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Log       string    `json:"log,omitempty"`
+}
+
+func (m *TaskManager) webhooks(task string) []webhookConfig {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if t, ok := m.Tasks[task]; ok && len(t.notifications) > 0 {
+		return t.notifications
+	}
+	return m.Config.Notifications
+}
+
+// logExcerpt returns last lines of output of the task run
+func (m *TaskManager) logExcerpt(task string, id int) string {
+	f, compressed, err := m.OpenLog(task, id)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if compressed {
+		if r, err = gzip.NewReader(f); err != nil {
+			return ""
+		}
+	}
+	var buf bytes.Buffer
+	writeLogs(&buf, r, logQuery{Format: "text", Tail: excerptLines})
+	excerpt := buf.Bytes()
+	if len(excerpt) > excerptSize {
+		excerpt = excerpt[runeStart(excerpt, len(excerpt)-excerptSize):]
+	}
+	return string(excerpt)
+}
+
+func (m *TaskManager) notifyStarted(task *Task, run TaskStats) {
+	m.notify(notification{Event: eventStart, Task: task.Name, RunID: run.ID, StartTime: run.StartTime})
+}
+
+func (m *TaskManager) notifyFinished(task *Task, run TaskStats) {
+	n := notification{
+		Event:     eventSuccess,
+		Task:      task.Name,
+		RunID:     run.ID,
+		StartTime: run.StartTime,
+		Duration:  run.Duration,
+		Error:     m.redactor.Redact(run.Error),
+	}
+	switch {
+	case run.TimedOut:
+		n.Event = eventTimeout
+	case run.Crashed || run.Status != 0:
+		n.Event = eventFailure
+	}
+	if !run.Crashed {
+		n.Status = &run.Status
+	}
+	m.notify(n)
+}
+
+// notify sends notification to webhooks accepting its event (in background)
+func (m *TaskManager) notify(n notification) {
+	var hooks []webhookConfig
+	for _, hook := range m.webhooks(n.Task) {
+		if hook.accepts(n.Event) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	if n.Event != eventStart {
+		n.Log = m.logExcerpt(n.Task, n.RunID)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		LogError("Failed to serialize notification", Fields{"task": n.Task, "run_id": n.RunID, "error": err})
+		return
+	}
+	for _, hook := range hooks {
+		go sendWebhook(hook, data, Fields{"task": n.Task, "run_id": n.RunID, "event": n.Event})
+	}
+}
+
+// sendWebhook posts notification, failed requests (network errors, 429 and
+// 5xx responses) are retried with exponential backoff
+func sendWebhook(hook webhookConfig, data []byte, fields Fields) {
+	client := &http.Client{Timeout: webhookTimeout}
+	// URL can contain access tokens
+	if u, err := url.Parse(hook.URL); err == nil {
+		fields = fields.With("webhook", u.Host)
+	}
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postWebhook(client, hook, data)
+		if err == nil {
+			LogDebug("Notification sent", fields)
+			return
+		}
+		if !retry || attempt == webhookAttempts {
+			LogError("Failed to send notification", fields.With("attempts", attempt).With("error", err))
+			return
+		}
+		LogDebug("Notification failed, retrying", fields.With("attempt", attempt).With("error", err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func postWebhook(client *http.Client, hook webhookConfig, data []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dcron")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook responded with status %s", strings.TrimSpace(resp.Status))
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package dcron
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyFinishedReportsFiredRun(t *testing.T) {
+	received := make(chan notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := newTestTaskManager()
+	m.LogsRoot = dir
+	log := `{"log":"connection refused\n","stream":"stderr","time":"2020-01-01T00:00:00Z"}` + "\n"
+	if err := ioutil.WriteFile(m.GetLogfilePath("backup", 1), []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.Config.Notifications = []webhookConfig{{URL: server.URL}}
+	// newer run of the same task is already running
+	m.Stats = &tasksStats{Tasks: map[string][]*TaskStats{
+		"backup": {{ID: 1, Status: 1}, {ID: 2, Running: true}},
+	}}
+	start := time.Now()
+	m.notifyFinished(&Task{Name: "backup"}, TaskStats{ID: 1, StartTime: start, Status: 1})
+
+	select {
+	case n := <-received:
+		if n.Event != eventFailure || n.RunID != 1 || !n.StartTime.Equal(start) || n.Status == nil || *n.Status != 1 {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+		if !strings.Contains(n.Log, "connection refused") {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not sent")
+	}
+}
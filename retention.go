@@ -0,0 +1,345 @@
+package dcron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// interval of background compression and pruning of old logs
+const pruneInterval = 10 * time.Minute
+
+// byteSize size in bytes, in YAML also as a string with unit (e.g. 500MB or 1GB),
+// units are powers of 1024
+type byteSize int64
+
+var byteSizeRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?)(?:i?b)?$`)
+
+var byteSizeUnits = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+func parseByteSize(s string) (byteSize, error) {
+	m := byteSizeRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return byteSize(value * byteSizeUnits[m[2]]), nil
+}
+
+func (s *byteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case int:
+		*s = byteSize(v)
+	case string:
+		size, err := parseByteSize(v)
+		if err != nil {
+			return unmarshalError("%s", err)
+		}
+		*s = size
+	default:
+		return unmarshalError("invalid size %v", value)
+	}
+	return nil
+}
+
+// logsConfig retention of tasks logs
+type logsConfig struct {
+	KeepRuns         int            `yaml:"keep_runs,omitempty"`
+	KeepDays         int            `yaml:"keep_days,omitempty"`
+	MaxTotalSize     byteSize       `yaml:"max_total_size,omitempty"`
+	MaxLogSize       byteSize       `yaml:"max_log_size,omitempty"`
+	MaxArtifactsSize byteSize       `yaml:"max_artifacts_size,omitempty"`
+	Compress         logCompression `yaml:"compress,omitempty"`
+	Sinks            []sinkConfig   `yaml:"sinks,omitempty"`
+}
+
+func validateLogs(c logsConfig) []fieldError {
+	var errors []fieldError
+	if c.KeepRuns < 0 {
+		errors = append(errors, fieldError{"logs.keep_runs", "must not be negative"})
+	}
+	if c.KeepDays < 0 {
+		errors = append(errors, fieldError{"logs.keep_days", "must not be negative"})
+	}
+	if c.MaxTotalSize < 0 {
+		errors = append(errors, fieldError{"logs.max_total_size", "must not be negative"})
+	}
+	if c.MaxLogSize < 0 {
+		errors = append(errors, fieldError{"logs.max_log_size", "must not be negative"})
+	}
+	if c.MaxArtifactsSize < 0 {
+		errors = append(errors, fieldError{"logs.max_artifacts_size", "must not be negative"})
+	}
+	if err := c.Compress.validate(); err != nil {
+		errors = append(errors, fieldError{"logs.compress", err.Error()})
+	}
+	for i, sink := range c.Sinks {
+		if err := sink.validate(); err != nil {
+			errors = append(errors, fieldError{fmt.Sprintf("logs.sinks.%d", i), err.Error()})
+		}
+	}
+	return errors
+}
+
+// logFile log file of a task run, stats are nil for logs of previous dcron runs
+type logFile struct {
+	id         int
+	path       string
+	time       time.Time
+	modTime    time.Time
+	size       int64
+	compressed bool
+	stats      *TaskStats
+}
+
+var logFileRe = regexp.MustCompile(`^(.+)\.(\d+)\.log(\.gz)?$`)
+
+// artifacts directories of tasks runs
+var artifactsDirRe = regexp.MustCompile(`^(.+)\.(\d+)$`)
+
+// lastRunIDs returns highest run IDs of tasks with logs or artifacts in logs directory
+func lastRunIDs(logsRoot string) map[string]int {
+	ids := make(map[string]int)
+	entries, err := ioutil.ReadDir(logsRoot)
+	if err != nil {
+		LogError("Failed to read logs directory", Fields{"error": err})
+		return ids
+	}
+	for _, entry := range entries {
+		match := logFileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() {
+			match = artifactsDirRe.FindStringSubmatch(entry.Name())
+		}
+		if match == nil {
+			continue
+		}
+		if id, _ := strconv.Atoi(match[2]); id > ids[match[1]] {
+			ids[match[1]] = id
+		}
+	}
+	return ids
+}
+
+// nextRunID returns ID of a new run of the task. IDs continue after runs of
+// previous dcron runs found in logs directory (Stats lock must be held).
+func (m *TaskManager) nextRunID(task string) int {
+	if m.Stats.lastIDs == nil {
+		m.Stats.lastIDs = lastRunIDs(m.LogsRoot)
+	}
+	id := m.Stats.lastIDs[task]
+	for _, stats := range m.Stats.Tasks[task] {
+		if stats.ID > id {
+			id = stats.ID
+		}
+	}
+	m.Stats.lastIDs[task] = id + 1
+	return id + 1
+}
+
+// statsSnapshot returns copies of stats of tasks runs
+func (m *TaskManager) statsSnapshot() map[string][]*TaskStats {
+	m.Stats.RLock()
+	defer m.Stats.RUnlock()
+	snapshot := make(map[string][]*TaskStats, len(m.Stats.Tasks))
+	for task, entries := range m.Stats.Tasks {
+		copies := make([]*TaskStats, len(entries))
+		for i, entry := range entries {
+			stats := *entry
+			copies[i] = &stats
+		}
+		snapshot[task] = copies
+	}
+	return snapshot
+}
+
+// logFiles returns log files of tasks (by task name) sorted from the oldest,
+// matched with stats of their runs
+func (m *TaskManager) logFiles(tasksStats map[string][]*TaskStats) (map[string][]*logFile, error) {
+	entries, err := ioutil.ReadDir(m.LogsRoot)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]*logFile)
+	for _, entry := range entries {
+		match := logFileRe.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		task := match[1]
+		id, _ := strconv.Atoi(match[2])
+		file := &logFile{
+			id:         id,
+			path:       filepath.Join(m.LogsRoot, entry.Name()),
+			time:       entry.ModTime(),
+			modTime:    entry.ModTime(),
+			size:       entry.Size() + artifactsSize(m.GetArtifactsPath(task, id)),
+			compressed: match[3] != "",
+		}
+		for _, stats := range tasksStats[task] {
+			// log is written since the start of the run
+			if stats.ID == id && !entry.ModTime().Before(stats.StartTime.Truncate(time.Second)) {
+				file.stats = stats
+				file.time = stats.StartTime
+			}
+		}
+		files[task] = append(files[task], file)
+	}
+	for _, taskFiles := range files {
+		sort.SliceStable(taskFiles, func(i, j int) bool {
+			return taskFiles[i].time.Before(taskFiles[j].time)
+		})
+	}
+	return files, nil
+}
+
+// PruneLogs removes logs (and stats) of old runs exceeding configured
+// retention. The last run of a task and running tasks are always kept.
+func (m *TaskManager) PruneLogs() {
+	m.pruneMutex.Lock()
+	defer m.pruneMutex.Unlock()
+
+	m.mutex.RLock()
+	global := m.Config.Logs
+	configs := make(map[string]logsConfig, len(m.Tasks))
+	for name, task := range m.Tasks {
+		configs[name] = task.logs
+	}
+	m.mutex.RUnlock()
+
+	// files are listed and removed without holding the stats lock
+	now := time.Now()
+	files, err := m.logFiles(m.statsSnapshot())
+	if err != nil {
+		LogError("Failed to read logs directory", Fields{"error": err})
+		return
+	}
+	// logs of runs started after the snapshot
+	recent := now.Truncate(time.Second)
+
+	removed := make(map[*logFile]bool)
+	var candidates []*logFile
+	for task, taskFiles := range files {
+		conf := configs[task]
+		keepRuns, keepDays := conf.KeepRuns, conf.KeepDays
+		if keepRuns == 0 {
+			keepRuns = global.KeepRuns
+		}
+		if keepDays == 0 {
+			keepDays = global.KeepDays
+		}
+		var total int64
+		for _, file := range taskFiles {
+			total += file.size
+		}
+		for i, file := range taskFiles {
+			if i == len(taskFiles)-1 || (file.stats != nil && file.stats.Running) || !file.modTime.Before(recent) {
+				continue
+			}
+			if (keepRuns > 0 && i < len(taskFiles)-keepRuns) ||
+				(keepDays > 0 && now.Sub(file.time) > time.Duration(keepDays)*24*time.Hour) ||
+				(conf.MaxTotalSize > 0 && total > int64(conf.MaxTotalSize)) {
+				removed[file] = true
+				total -= file.size
+				continue
+			}
+			candidates = append(candidates, file)
+		}
+	}
+
+	if global.MaxTotalSize > 0 {
+		var total int64
+		for _, taskFiles := range files {
+			for _, file := range taskFiles {
+				if !removed[file] {
+					total += file.size
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].time.Before(candidates[j].time)
+		})
+		for _, file := range candidates {
+			if total <= int64(global.MaxTotalSize) {
+				break
+			}
+			removed[file] = true
+			total -= file.size
+		}
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+	count := 0
+	removedRuns := make(map[string]map[int]bool)
+	for task, taskFiles := range files {
+		for _, file := range taskFiles {
+			if !removed[file] {
+				continue
+			}
+			if err := os.Remove(file.path); err != nil {
+				LogError("Failed to remove log file", Fields{"task": task, "path": file.path, "error": err})
+				continue
+			}
+			if err := os.RemoveAll(m.GetArtifactsPath(task, file.id)); err != nil {
+				LogError("Failed to remove artifacts", Fields{"task": task, "run_id": file.id, "error": err})
+			}
+			count++
+			if file.stats != nil {
+				if removedRuns[task] == nil {
+					removedRuns[task] = make(map[int]bool)
+				}
+				removedRuns[task][file.id] = true
+			}
+		}
+	}
+	m.Stats.Lock()
+	for task, ids := range removedRuns {
+		stats := make([]*TaskStats, 0, len(m.Stats.Tasks[task]))
+		for _, entry := range m.Stats.Tasks[task] {
+			if !ids[entry.ID] {
+				stats = append(stats, entry)
+			}
+		}
+		if len(stats) > 0 {
+			m.Stats.Tasks[task] = stats
+		} else {
+			delete(m.Stats.Tasks, task)
+		}
+	}
+	m.Stats.Unlock()
+	LogInfo("Removed old log files", Fields{"count": count})
+}
+
+func (m *TaskManager) pruneLogsPeriodically(stop chan struct{}) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.CompressLogs()
+			m.PruneLogs()
+		case <-stop:
+			return
+		}
+	}
+}
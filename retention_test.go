@@ -0,0 +1,139 @@
+package dcron
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextRunID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"backup.3.log.gz", "backup.1.log", "db.dump.2.log", "schedule.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "backup.5"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &TaskManager{LogsRoot: dir, Stats: &tasksStats{Tasks: make(map[string][]*TaskStats)}}
+	tests := []struct {
+		task string
+		id   int
+	}{
+		{"backup", 6},
+		{"backup", 7},
+		{"db.dump", 3},
+		{"new", 1},
+	}
+	for _, test := range tests {
+		if id := m.nextRunID(test.task); id != test.id {
+			t.Errorf("nextRunID(%q) = %d, expected %d", test.task, id, test.id)
+		}
+	}
+}
+
+func TestLogFilesMatchStatsByTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.1.log")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// log of previous dcron run
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+
+	stats := &TaskStats{ID: 1, StartTime: time.Now()}
+	m := &TaskManager{LogsRoot: dir, Stats: &tasksStats{Tasks: map[string][]*TaskStats{"backup": {stats}}}}
+	files, err := m.logFiles(m.Stats.Tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["backup"][0].stats != nil {
+		t.Error("old log file is matched with stats of the new run")
+	}
+
+	os.Chtimes(path, time.Now(), time.Now())
+	files, _ = m.logFiles(m.Stats.Tasks)
+	if files["backup"][0].stats != stats {
+		t.Error("log file is not matched with stats of its run")
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcron-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now().Add(-10 * time.Hour)
+	tasks := map[string][]*TaskStats{"backup": {
+		{ID: 2, StartTime: start.Add(2 * time.Hour)},
+		{ID: 3, StartTime: start.Add(3 * time.Hour), Running: true},
+		{ID: 4, StartTime: start.Add(4 * time.Hour)},
+	}}
+	m := newTestTaskManager()
+	m.LogsRoot = dir
+	m.Config.Logs.KeepRuns = 2
+	m.Stats = &tasksStats{Tasks: tasks}
+	// run 1 is from previous dcron run
+	for id := 1; id <= 4; id++ {
+		path := m.GetLogfilePath("backup", id)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := start.Add(time.Duration(id)*time.Hour + time.Minute)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	m.PruneLogs()
+	files, err := m.logFiles(m.Stats.Tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, file := range files["backup"] {
+		ids = append(ids, file.id)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 4 {
+		t.Errorf("remaining logs %v, expected [3 4]", ids)
+	}
+	if stats := m.Stats.Tasks["backup"]; len(stats) != 2 || stats[0].ID != 3 || stats[1].ID != 4 {
+		t.Errorf("stats of removed runs are kept: %+v", stats)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		size  byteSize
+		err   bool
+	}{
+		{"1024", 1024, false},
+		{"500MB", 500 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"1.5 kib", 1536, false},
+		{" 2T ", 2 << 40, false},
+		{"10b", 10, false},
+		{"1PB", 0, true},
+		{"-1GB", 0, true},
+		{"big", 0, true},
+	}
+	for _, test := range tests {
+		size, err := parseByteSize(test.value)
+		if (err != nil) != test.err || size != test.size {
+			t.Errorf("parseByteSize(%q) = %d, %v, expected %d", test.value, size, err, test.size)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package dcron
+
+import (
+	"reflect"
+	"strings"
+)
+
+type jsonSchema map[string]interface{}
+
+var stringSchema = jsonSchema{"type": "string"}
+
+// schemas of types with custom YAML unmarshalling
+var customSchemas = map[reflect.Type]jsonSchema{
+	reflect.TypeOf(strSlice{}): {
+		"oneOf": []jsonSchema{
+			stringSchema,
+			{"type": "array", "items": stringSchema},
+		},
+	},
+	reflect.TypeOf(taskSecrets{}): {
+		"type": "array",
+		"items": jsonSchema{
+			"oneOf": []jsonSchema{
+				stringSchema,
+				{
+					"type": "object",
+					"properties": jsonSchema{
+						"source": stringSchema,
+						"target": stringSchema,
+						"env":    stringSchema,
+					},
+					"required":             []string{"source"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	},
+	reflect.TypeOf(byteSize(0)): {
+		"oneOf": []jsonSchema{
+			{"type": "integer", "minimum": 0},
+			{"type": "string", "pattern": "^[0-9]+(\\.[0-9]+)?\\s*([kKmMgGtT]?)([iI]?[bB])?$"},
+		},
+	},
+	reflect.TypeOf(logCompression("")): {
+		"oneOf": []jsonSchema{
+			{"type": "boolean"},
+			{"type": "string", "pattern": "^(never|finish|([0-9]+(\\.[0-9]+)?(ns|us|ms|s|m|h))+)$"},
+		},
+	},
+	reflect.TypeOf(envMap{}): {
+		"oneOf": []jsonSchema{
+			{"type": "array", "items": stringSchema},
+			{"type": "object", "additionalProperties": jsonSchema{"type": []string{"string", "number", "boolean", "null"}}},
+		},
+	},
+}
+
+var scalarSchema = jsonSchema{"type": []string{"string", "number", "boolean"}}
+
+// schemas of fields (by YAML name) more specific than their type
+var fieldSchemas = map[string]jsonSchema{
+	"catchup": {"type": "string", "enum": []string{catchupNone, catchupLast, catchupAll}},
+	"type":    {"type": "string", "enum": []string{sinkSyslog, sinkGELF, sinkLoki}},
+	"matrix":  {"type": "object", "additionalProperties": jsonSchema{"type": "array", "items": scalarSchema}},
+	"on":      {"type": "array", "items": jsonSchema{"type": "string", "enum": []string{eventStart, eventSuccess, eventFailure, eventTimeout}}},
+}
+
+func yamlFieldName(field reflect.StructField) (string, bool) {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")
+	inline := false
+	for _, flag := range tag[1:] {
+		inline = inline || flag == "inline"
+	}
+	if tag[0] != "" {
+		return tag[0], inline
+	}
+	// default name used by yaml package
+	return strings.ToLower(field.Name), inline
+}
+
+func structSchema(t reflect.Type, properties jsonSchema, schema jsonSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, inline := yamlFieldName(field)
+		if (field.PkgPath != "" && !field.Anonymous) || name == "-" {
+			continue
+		}
+		if inline && field.Type.Kind() == reflect.Struct {
+			structSchema(field.Type, properties, schema)
+			continue
+		}
+		if inline && field.Type.Kind() == reflect.Map {
+			// extension fields
+			schema["patternProperties"] = jsonSchema{"^x-": jsonSchema{}}
+			continue
+		}
+		if fieldSchema, ok := fieldSchemas[name]; ok {
+			properties[name] = fieldSchema
+		} else {
+			properties[name] = typeSchema(field.Type)
+		}
+	}
+}
+
+func typeSchema(t reflect.Type) jsonSchema {
+	if schema, ok := customSchemas[t]; ok {
+		return schema
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringSchema
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return jsonSchema{"type": "number"}
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return jsonSchema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := jsonSchema{}
+		schema := jsonSchema{"type": "object", "properties": properties, "additionalProperties": false}
+		structSchema(t, properties, schema)
+		return schema
+	}
+	return jsonSchema{}
+}
+
+// ConfigSchema returns JSON Schema of tasks configuration file
+func ConfigSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(TasksConfig{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "dcron tasks configuration"
+	return schema
+}
@@ -0,0 +1,168 @@
+package dcron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// checkSchemaFields checks that schema has properties of all fields of the type
+func checkSchemaFields(t *testing.T, typ reflect.Type, schema jsonSchema, path string) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if _, ok := customSchemas[typ]; ok {
+		return
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		properties, _ := schema["properties"].(jsonSchema)
+		fields := yamlFields(typ)
+		if len(properties) != len(fields) {
+			t.Errorf("%s: %d schema properties, expected %d", path, len(properties), len(fields))
+		}
+		for key, fieldType := range fields {
+			property, ok := properties[key].(jsonSchema)
+			if !ok {
+				t.Errorf("%s: missing property %q", path, key)
+				continue
+			}
+			if _, ok := fieldSchemas[key]; !ok {
+				checkSchemaFields(t, fieldType, property, path+"."+key)
+			}
+		}
+	case reflect.Map:
+		checkSchemaFields(t, typ.Elem(), schema["additionalProperties"].(jsonSchema), path+".*")
+	case reflect.Slice:
+		checkSchemaFields(t, typ.Elem(), schema["items"].(jsonSchema), path+"[]")
+	}
+}
+
+func TestConfigSchemaFields(t *testing.T) {
+	checkSchemaFields(t, reflect.TypeOf(TasksConfig{}), ConfigSchema(), "config")
+}
+
+// checkSchemaKeys checks that all keys of YAML document are defined in schema
+func checkSchemaKeys(t *testing.T, node interface{}, schema jsonSchema, path string) {
+	kind := "object"
+	if _, ok := node.([]interface{}); ok {
+		kind = "array"
+	}
+	if alternatives, ok := schema["oneOf"].([]jsonSchema); ok {
+		for _, alternative := range alternatives {
+			if alternative["type"] == kind {
+				schema = alternative
+				break
+			}
+		}
+	}
+	switch value := node.(type) {
+	case yaml.MapSlice:
+		properties, _ := schema["properties"].(jsonSchema)
+		for _, item := range value {
+			key := fmt.Sprint(item.Key)
+			if item.Key == true {
+				// on: is YAML 1.1 boolean
+				key = "on"
+			}
+			if property, ok := properties[key].(jsonSchema); ok {
+				checkSchemaKeys(t, item.Value, property, path+"."+key)
+			} else if additional, ok := schema["additionalProperties"].(jsonSchema); ok {
+				checkSchemaKeys(t, item.Value, additional, path+"."+key)
+			} else if _, ok := schema["patternProperties"]; !ok || !strings.HasPrefix(key, "x-") {
+				t.Errorf("%s: key %q is not defined in schema", path, key)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(jsonSchema); ok {
+			for _, item := range value {
+				checkSchemaKeys(t, item, items, path+"[]")
+			}
+		}
+	}
+}
+
+const schemaExample = `
+defaults:
+  run:
+    network_mode: host
+    mirror_output: false
+secrets:
+  db:
+    environment: DB_PASSWORD
+logs:
+  keep_runs: 10
+  keep_days: 30
+  max_total_size: 1GB
+  max_log_size: 10MB
+  max_artifacts_size: 100MB
+  compress: 24h
+  sinks:
+    - type: loki
+      address: http://loki:3100/loki/api/v1/push
+      labels: {env: prod}
+notifications:
+  - url: https://hooks.example.com/dcron
+    on: [failure, timeout]
+    headers: {Authorization: Bearer token}
+run:
+  report:
+    description: Report of {{ matrix.db }}
+    tags: [reports]
+    owner: ops
+    schedule: "0 6 * * *"
+    catchup: all
+    image: alpine
+    entrypoint: sh
+    command: ["-c", "report {{ matrix.db }}"]
+    environment: {DB: "{{ matrix.db }}"}
+    matrix:
+      db: [orders, users]
+    volumes: ["reports:/reports"]
+    artifacts: [/reports]
+    timeout: 30m
+    stop_signal: SIGTERM
+    secrets: [{source: db, env: DB_PASSWORD}]
+    logs:
+      keep_runs: 5
+    x-note: extension
+exec:
+  vacuum:
+    service: db
+    user: postgres
+    command: vacuumdb
+    environment: [PGDATABASE=orders]
+`
+
+func TestConfigSchemaExamples(t *testing.T) {
+	readme, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tasks.yml example of README
+	parts := strings.SplitN(string(readme), "### dcron/tasks.yml\n```yaml\n", 2)
+	if len(parts) != 2 {
+		t.Fatal("tasks.yml example not found in README")
+	}
+	examples := map[string]string{
+		"README":     strings.SplitN(parts[1], "```", 2)[0],
+		"all fields": schemaExample,
+	}
+	schema := ConfigSchema()
+	for name, example := range examples {
+		t.Run(name, func(t *testing.T) {
+			if errors := ValidateConfig([]byte(example)); len(errors) > 0 {
+				t.Fatalf("invalid example: %v", errors)
+			}
+			var document yaml.MapSlice
+			if err := yaml.Unmarshal([]byte(example), &document); err != nil {
+				t.Fatal(err)
+			}
+			checkSchemaKeys(t, document, schema, "config")
+		})
+	}
+}
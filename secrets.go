@@ -0,0 +1,398 @@
+package dcron
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// default location of secret files (Docker secrets) in dcron and task containers
+const secretsDir = "/run/secrets"
+
+// secret values shorter than this are not redacted (too many false matches)
+const minRedactedLength = 4
+
+type secretDefinition struct {
+	File        string `yaml:"file,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+}
+
+type taskSecret struct {
+	Source string `yaml:"source"`
+	Target string `yaml:"target,omitempty"`
+	Env    string `yaml:"env,omitempty"`
+}
+
+type taskSecrets []taskSecret
+
+func (s *taskSecrets) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []interface{}
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	secrets := make(taskSecrets, 0, len(items))
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			secrets = append(secrets, taskSecret{Source: value})
+		case map[interface{}]interface{}:
+			secret := taskSecret{}
+			for key, val := range value {
+				str, ok := val.(string)
+				if !ok {
+					return unmarshalError("Unsupported value of secret's %v field: %v", key, val)
+				}
+				switch key {
+				case "source":
+					secret.Source = str
+				case "target":
+					secret.Target = str
+				case "env":
+					secret.Env = str
+				default:
+					return unmarshalError("Unsupported secret's field: %v", key)
+				}
+			}
+			secrets = append(secrets, secret)
+		default:
+			return unmarshalError("Unsupported secret definition: %v", item)
+		}
+	}
+	*s = secrets
+	return nil
+}
+
+func (d secretDefinition) value() (string, error) {
+	if d.File != "" {
+		file := d.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(secretsDir, file)
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	value, ok := os.LookupEnv(d.Environment)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", d.Environment)
+	}
+	return value, nil
+}
+
+func validateSecrets(definitions map[string]secretDefinition, secrets taskSecrets, files bool) error {
+	for _, secret := range secrets {
+		if _, ok := definitions[secret.Source]; !ok {
+			return fmt.Errorf("undefined secret %q", secret.Source)
+		}
+		if !files && secret.Env == "" {
+			return fmt.Errorf("secret %q must be passed as environment variable (env:)", secret.Source)
+		}
+		if secret.Target != "" && secret.Env != "" {
+			return fmt.Errorf("secret %q can't have both target and env", secret.Source)
+		}
+	}
+	return nil
+}
+
+// replacement of redacted values
+const redacted = "******"
+
+// redactor replaces secret values in text
+type redactor struct {
+	sync.RWMutex
+	values []string
+	// values used by running tasks (with number of runs), redacted
+	// until the runs finish even when secrets are changed
+	held map[string]int
+}
+
+// Set replaces values of defined secrets at once
+func (r *redactor) Set(values []string) {
+	unique := make([]string, 0, len(values))
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if len(value) >= minRedactedLength && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	r.Lock()
+	r.values = unique
+	r.Unlock()
+}
+
+// Hold redacts values until they are released
+func (r *redactor) Hold(values []string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.held == nil {
+		r.held = make(map[string]int)
+	}
+	for _, value := range values {
+		if len(value) >= minRedactedLength {
+			r.held[value]++
+		}
+	}
+}
+
+func (r *redactor) Release(values []string) {
+	r.Lock()
+	defer r.Unlock()
+	for _, value := range values {
+		if n := r.held[value]; n > 1 {
+			r.held[value] = n - 1
+		} else {
+			delete(r.held, value)
+		}
+	}
+}
+
+func (r *redactor) Redact(text string) string {
+	r.RLock()
+	defer r.RUnlock()
+	for _, value := range r.values {
+		text = strings.Replace(text, value, redacted, -1)
+	}
+	for value := range r.held {
+		text = strings.Replace(text, value, redacted, -1)
+	}
+	return text
+}
+
+// redactedLength returns length of the text beginning which can be redacted
+// independently of following text, the rest can be a part of secret value
+func (r *redactor) redactedLength(text string) int {
+	r.RLock()
+	values := make([]string, 0, len(r.values)+len(r.held))
+	values = append(values, r.values...)
+	for value := range r.held {
+		values = append(values, value)
+	}
+	r.RUnlock()
+	longest := 0
+	for _, value := range values {
+		if len(value) > longest {
+			longest = len(value)
+		}
+	}
+	cut := 0
+	for {
+		// the longest end of text which is beginning of some value
+		partial := len(text)
+		start := cut
+		if start < len(text)-longest+1 {
+			start = len(text) - longest + 1
+		}
+	search:
+		for i := start; i < len(text); i++ {
+			for _, value := range values {
+				if len(text)-i < len(value) && strings.HasPrefix(value, text[i:]) {
+					partial = i
+					break search
+				}
+			}
+		}
+		// values which would be split by the cut
+		end := partial
+		for _, value := range values {
+			from := partial - len(value) + 1
+			if from < 0 {
+				from = 0
+			}
+			if i := strings.Index(text[from:], value); i >= 0 && from+i < partial && from+i+len(value) > end {
+				end = from + i + len(value)
+			}
+		}
+		if end == partial {
+			return partial
+		}
+		cut = end
+	}
+}
+
+// redactWriter writer with redacted secrets. Secrets split between
+// multiple writes are not detected.
+type redactWriter struct {
+	redactor *redactor
+	w        io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactWriter returns writer which hides values of configured secrets
+func (m *TaskManager) RedactWriter(w io.Writer) io.Writer {
+	return &redactWriter{m.redactor, w}
+}
+
+// loadSecrets reads values of all defined secrets for redaction
+func (m *TaskManager) loadSecrets(definitions map[string]secretDefinition) {
+	values := make([]string, 0, len(definitions))
+	for name, definition := range definitions {
+		value, err := definition.value()
+		if err != nil {
+			LogError("Failed to read secret", Fields{"secret": name, "error": err})
+			continue
+		}
+		values = append(values, value)
+	}
+	m.redactor.Set(values)
+}
+
+func (m *TaskManager) secretValue(name string) (string, error) {
+	m.mutex.RLock()
+	definition, ok := m.Config.Secrets[name]
+	m.mutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("undefined secret %q", name)
+	}
+	value, err := definition.value()
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %s", name, err)
+	}
+	return value, nil
+}
+
+// resolveSecrets returns environment variables and files (by path) with values of task's secrets,
+// the values are redacted until the task run finishes
+func (m *TaskManager) resolveSecrets(logger Logger, secrets taskSecrets) ([]string, map[string]string, error) {
+	env := make([]string, 0)
+	files := make(map[string]string)
+	for _, secret := range secrets {
+		value, err := m.secretValue(secret.Source)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.HoldSecrets(value)
+		if secret.Env != "" {
+			env = append(env, fmt.Sprintf("%s=%s", secret.Env, value))
+			continue
+		}
+		target := secret.Target
+		if target == "" {
+			target = secret.Source
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(secretsDir, target)
+		}
+		files[target] = value
+	}
+	return env, files, nil
+}
+
+// secretsArchive creates tar archive (extracted to /) with secret files
+func secretsArchive(files map[string]string) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	archive := tar.NewWriter(buf)
+	for target, value := range files {
+		header := &tar.Header{
+			Name: strings.TrimPrefix(target, "/"),
+			Mode: 0444,
+			Size: int64(len(value)),
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := archive.Write([]byte(value)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// redactURL hides password of URL's user info
+func redactURL(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.User == nil {
+		return address
+	}
+	u.User = url.UserPassword(u.User.Username(), "xxxxxx")
+	return strings.Replace(u.String(), "xxxxxx", redacted, 1)
+}
+
+func redactEnv(env envMap) envMap {
+	if env == nil {
+		return nil
+	}
+	copy := make(envMap, len(env))
+	for key := range env {
+		copy[key] = redacted
+	}
+	return copy
+}
+
+func redactLogs(logs logsConfig) logsConfig {
+	if logs.Sinks == nil {
+		return logs
+	}
+	sinks := make([]sinkConfig, len(logs.Sinks))
+	for i, sink := range logs.Sinks {
+		sink.Address = redactURL(sink.Address)
+		sinks[i] = sink
+	}
+	logs.Sinks = sinks
+	return logs
+}
+
+func redactNotifications(hooks []webhookConfig) []webhookConfig {
+	if hooks == nil {
+		return nil
+	}
+	copy := make([]webhookConfig, len(hooks))
+	for i, hook := range hooks {
+		hook.URL = redactURL(hook.URL)
+		headers := hook.Headers
+		hook.Headers = make(map[string]string, len(headers))
+		for key := range headers {
+			hook.Headers[key] = redacted
+		}
+		copy[i] = hook
+	}
+	return copy
+}
+
+func redactBaseTask(task baseTask) baseTask {
+	task.Environment = redactEnv(task.Environment)
+	task.Logs = redactLogs(task.Logs)
+	task.Notifications = redactNotifications(task.Notifications)
+	return task
+}
+
+// redactConfig returns copy of configuration with hidden environment
+// variables, webhooks headers and credentials of URLs
+func redactConfig(config TasksConfig) TasksConfig {
+	config.Defaults.Run.baseTask = redactBaseTask(config.Defaults.Run.baseTask)
+	config.Defaults.Exec.baseTask = redactBaseTask(config.Defaults.Exec.baseTask)
+	config.Logs = redactLogs(config.Logs)
+	config.Notifications = redactNotifications(config.Notifications)
+	run := make(map[string]runTask, len(config.Run))
+	for name, task := range config.Run {
+		task.baseTask = redactBaseTask(task.baseTask)
+		run[name] = task
+	}
+	config.Run = run
+	exec := make(map[string]execTask, len(config.Exec))
+	for name, task := range config.Exec {
+		task.baseTask = redactBaseTask(task.baseTask)
+		exec[name] = task
+	}
+	config.Exec = exec
+	return config
+}
@@ -1,18 +1,21 @@
 package dcron
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v2"
 )
 
 // Server web server API for task manager
@@ -29,17 +32,22 @@ type PublicServer struct {
 }
 
 type taskInfo struct {
-	Name     string      `json:"name"`
-	Schedule string      `json:"schedule,omitempty"`
-	Next     *time.Time  `json:"next,omitempty"`
-	Stats    []TaskStats `json:"stats"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Schedule    string            `json:"schedule,omitempty"`
+	Next        *time.Time        `json:"next,omitempty"`
+	Stats       []TaskStats       `json:"stats"`
+	Group       string            `json:"group,omitempty"`
+	Matrix      map[string]string `json:"matrix,omitempty"`
 }
 
 func (s *Server) jsonResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(data)
 	if err != nil {
-		log.Printf("Failed to serialize JSON: %s\n", err)
+		LogError("Failed to serialize JSON", Fields{"error": err})
 		http.Error(w, "Server error", http.StatusInternalServerError)
 	}
 }
@@ -50,9 +58,12 @@ func (s *Server) getTaskInfo(task *Task) taskInfo {
 	copy := make([]TaskStats, len(stats))
 	for i, value := range stats {
 		copy[i] = *value
+		copy[i].Error = s.taskManager.redactor.Redact(value.Error)
 	}
 	s.taskManager.Stats.RUnlock()
 
+	s.taskManager.mutex.RLock()
+	defer s.taskManager.mutex.RUnlock()
 	var next *time.Time
 	if task.Schedule != "" {
 		entry := s.taskManager.Cron.Entry(task.EntryID)
@@ -60,28 +71,71 @@ func (s *Server) getTaskInfo(task *Task) taskInfo {
 	}
 	return taskInfo{
 		task.Name,
+		task.Description,
+		task.Tags,
+		task.Owner,
 		task.Schedule,
 		next,
 		copy,
+		task.Group,
+		task.Matrix,
 	}
 }
 
-// func (s *Server) handleTasksConfig(w http.ResponseWriter, r *http.Request) {
-// 	w.Header().Set("Content-Type", "text/yaml")
-// 	yaml.NewEncoder(w).Encode(s.taskManager.Config)
-// }
+func (s *Server) handleTasksConfig(w http.ResponseWriter, r *http.Request) {
+	s.taskManager.mutex.RLock()
+	data, err := yaml.Marshal(redactConfig(s.taskManager.Config))
+	s.taskManager.mutex.RUnlock()
+	if err != nil {
+		LogError("Failed to serialize YAML", Fields{"error": err})
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/yaml")
+	fmt.Fprint(w, s.taskManager.redactor.Redact(string(data)))
+}
+
+// filterTasks returns tasks matching query filters (all given tags and owner)
+func (s *Server) filterTasks(r *http.Request) []*Task {
+	query := r.URL.Query()
+	tags := query["tag"]
+	owner := query.Get("owner")
+	var tasks []*Task
+	for _, task := range s.taskManager.TasksList() {
+		if owner != "" && task.Owner != owner {
+			continue
+		}
+		if hasTags(task, tags) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+func hasTags(task *Task, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range task.Tags {
+			found = found || t == tag
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
 
 func (s *Server) handleTasksInfo(w http.ResponseWriter, r *http.Request) {
-	tasks := make(map[string]taskInfo, len(s.taskManager.Tasks))
-	for name, task := range s.taskManager.Tasks {
-		tasks[name] = s.getTaskInfo(task)
+	tasks := make(map[string]taskInfo)
+	for _, task := range s.filterTasks(r) {
+		tasks[task.Name] = s.getTaskInfo(task)
 	}
 	s.jsonResponse(w, tasks)
 }
 
 func (s *Server) handleTasksList(w http.ResponseWriter, r *http.Request) {
 	tasks := make([]taskInfo, 0)
-	for _, task := range s.taskManager.Tasks {
+	for _, task := range s.filterTasks(r) {
 		tasks = append(tasks, s.getTaskInfo(task))
 	}
 	s.jsonResponse(w, tasks)
@@ -89,7 +143,7 @@ func (s *Server) handleTasksList(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleTaskRun(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "task")
-	task, ok := s.taskManager.Tasks[name]
+	task, ok := s.taskManager.GetTask(name)
 	if !ok {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
@@ -102,16 +156,374 @@ func (s *Server) handleTaskRun(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "ok\n")
 }
 
+const (
+	defaultUpcomingCount = 10
+	maxUpcomingCount     = 1000
+)
+
+type upcomingInfo struct {
+	Name     string      `json:"name"`
+	Schedule string      `json:"schedule"`
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+func (s *Server) handleTaskUpcoming(w http.ResponseWriter, r *http.Request) {
+	task, ok := s.taskManager.GetTask(chi.URLParam(r, "task"))
+	if !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	count := defaultUpcomingCount
+	var until time.Time
+	if value := query.Get("until"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+		until = t
+		count = maxUpcomingCount
+	}
+	if value := query.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxUpcomingCount {
+			http.Error(w, "Invalid count parameter", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	s.taskManager.mutex.RLock()
+	schedule := task.Schedule
+	s.taskManager.mutex.RUnlock()
+
+	info := upcomingInfo{task.Name, schedule, []time.Time{}}
+	if schedule != "" {
+		times, err := UpcomingTimes(schedule, time.Now(), count, until)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info.Upcoming = times
+	}
+	s.jsonResponse(w, info)
+}
+
+func (s *Server) handleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, ConfigSchema())
+}
+
+type taskDefinition struct {
+	Run  *runTask  `yaml:"run,omitempty"`
+	Exec *execTask `yaml:"exec,omitempty"`
+}
+
+// validateTask validates task definition as a configuration file with a single
+// task, which can use secrets of loaded configuration and gets the same defaults
+// as tasks of the overlay file
+func (m *TaskManager) validateTask(name string, definition taskDefinition) []error {
+	m.mutex.RLock()
+	config := TasksConfig{Secrets: m.Config.Secrets}
+	m.mutex.RUnlock()
+	if m.ConfigStore != nil {
+		config.Defaults = m.ConfigStore.taskDefaults()
+	}
+	if definition.Run != nil {
+		config.Run = map[string]runTask{name: *definition.Run}
+	} else {
+		config.Exec = map[string]execTask{name: *definition.Exec}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return []error{err}
+	}
+	return ValidateConfig(data)
+}
+
+func (s *Server) handleTaskConfig(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "task")
+	tm := s.taskManager
+	definition := taskDefinition{}
+	tm.mutex.RLock()
+	if task, ok := tm.Config.Run[name]; ok {
+		task.baseTask = redactBaseTask(task.baseTask)
+		definition.Run = &task
+	} else if task, ok := tm.Config.Exec[name]; ok {
+		task.baseTask = redactBaseTask(task.baseTask)
+		definition.Exec = &task
+	}
+	tm.mutex.RUnlock()
+	if definition.Run == nil && definition.Exec == nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if tm.ConfigStore != nil {
+		w.Header().Set("ETag", strconv.Quote(tm.ConfigStore.Version()))
+	}
+	data, err := yaml.Marshal(definition)
+	if err != nil {
+		LogError("Failed to serialize YAML", Fields{"error": err})
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/yaml")
+	fmt.Fprint(w, tm.redactor.Redact(string(data)))
+}
+
+func (s *Server) updateConfig(w http.ResponseWriter, r *http.Request, update func(*overlayConfig)) {
+	store := s.taskManager.ConfigStore
+	if store == nil {
+		http.Error(w, "Configuration store not available", http.StatusNotImplemented)
+		return
+	}
+	config, err := store.Update(strings.Trim(r.Header.Get("If-Match"), `"`), update)
+	switch err.(type) {
+	case nil:
+	case InvalidConfigError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		switch err {
+		case ErrConfigChanged:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case ErrVersionMismatch:
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		default:
+			LogError("Failed to update configuration", Fields{"error": err})
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if _, err := s.taskManager.LoadConfig(config); err != nil {
+		LogError("Failed to load configuration", Fields{"error": err})
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("ETag", strconv.Quote(store.Version()))
+	fmt.Fprintf(w, "ok\n")
+}
+
+func (s *Server) handleTaskUpdate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "task")
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	definition := taskDefinition{}
+	if err := yaml.Unmarshal(body, &definition); err != nil || (definition.Run == nil) == (definition.Exec == nil) {
+		http.Error(w, "Invalid task definition, expected run: or exec: task", http.StatusBadRequest)
+		return
+	}
+	if errors := s.taskManager.validateTask(name, definition); len(errors) > 0 {
+		messages := make([]string, len(errors))
+		for i, err := range errors {
+			if e, ok := err.(ConfigError); ok {
+				messages[i] = e.Msg
+			} else {
+				messages[i] = err.Error()
+			}
+		}
+		http.Error(w, strings.Join(messages, "\n"), http.StatusBadRequest)
+		return
+	}
+	s.updateConfig(w, r, func(overlay *overlayConfig) {
+		removed := overlay.Removed[:0]
+		for _, item := range overlay.Removed {
+			if item != name {
+				removed = append(removed, item)
+			}
+		}
+		overlay.Removed = removed
+		delete(overlay.Run, name)
+		delete(overlay.Exec, name)
+		if definition.Run != nil {
+			if overlay.Run == nil {
+				overlay.Run = make(map[string]runTask)
+			}
+			overlay.Run[name] = *definition.Run
+		} else {
+			if overlay.Exec == nil {
+				overlay.Exec = make(map[string]execTask)
+			}
+			overlay.Exec[name] = *definition.Exec
+		}
+	})
+}
+
+func (s *Server) handleTaskDelete(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "task")
+	if !s.taskManager.hasTask(name) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	s.updateConfig(w, r, func(overlay *overlayConfig) {
+		delete(overlay.Run, name)
+		delete(overlay.Exec, name)
+		for _, item := range overlay.Removed {
+			if item == name {
+				return
+			}
+		}
+		overlay.Removed = append(overlay.Removed, name)
+	})
+}
+
 func (s *Server) handleTaskLogs(w http.ResponseWriter, r *http.Request) {
 	task := chi.URLParam(r, "task")
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	logfile := s.taskManager.GetLogfilePath(task, id)
-	http.ServeFile(w, r, logfile)
+	query, err := parseLogQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, compressed, err := s.taskManager.OpenLog(task, id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Log not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+	if query.Format != "" {
+		w.Header().Set("Content-Type", logContentTypes[query.Format])
+	}
+	if query.Raw() {
+		if !compressed {
+			info, err := f.Stat()
+			if err != nil {
+				http.Error(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+			http.ServeContent(w, r, f.Name(), info.ModTime(), f)
+			return
+		}
+		if query.Format == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Header().Set("Vary", "Accept-Encoding")
+		if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			w.Header().Set("Content-Encoding", "gzip")
+			io.Copy(w, f)
+			return
+		}
+	}
+	var reader io.Reader = f
+	if compressed {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		reader = zr
+	}
+	if query.Raw() {
+		io.Copy(w, reader)
+		return
+	}
+	if query.Format == "" {
+		w.Header().Set("Content-Type", logContentTypes["ndjson"])
+	}
+	if err := writeLogs(w, reader, query); err != nil {
+		LogError("Failed to read log file", Fields{"task": task, "run_id": id, "path": f.Name(), "error": err})
+	}
+}
+
+func (s *Server) handleTaskArtifacts(w http.ResponseWriter, r *http.Request) {
+	task := chi.URLParam(r, "task")
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if _, ok := s.taskManager.GetTask(task); !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	artifacts, err := s.taskManager.ListArtifacts(task, id)
+	if err != nil {
+		LogError("Failed to list artifacts", Fields{"task": task, "run_id": id, "error": err})
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, artifacts)
+}
+
+func (s *Server) handleTaskArtifact(w http.ResponseWriter, r *http.Request) {
+	task := chi.URLParam(r, "task")
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	if _, ok := s.taskManager.GetTask(task); !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	f, err := s.taskManager.OpenArtifact(task, id, chi.URLParam(r, "*"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Artifact not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *Server) handleLogsSearch(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query := logSearchQuery{
+		Text:       values.Get("q"),
+		Task:       values.Get("task"),
+		Limit:      50,
+		MaxMatches: 5,
+	}
+	if query.Text == "" {
+		http.Error(w, "Missing q parameter", http.StatusBadRequest)
+		return
+	}
+	if since := values.Get("since"); since != "" {
+		// time or age of logs (e.g. 720h)
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			age, durationErr := time.ParseDuration(since)
+			if durationErr != nil {
+				http.Error(w, "Invalid since parameter (RFC3339 time or duration)", http.StatusBadRequest)
+				return
+			}
+			t = time.Now().Add(-age)
+		}
+		query.Since = t
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, "Invalid limit parameter (1-500)", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+	results, err := s.taskManager.SearchLogs(query)
+	if err != nil {
+		LogError("Failed to search logs", Fields{"error": err})
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	s.jsonResponse(w, results)
 }
 
 func (s *Server) handleKillService(w http.ResponseWriter, r *http.Request) {
 	service := chi.URLParam(r, "service")
 	signal := r.URL.Query().Get("signal")
+	if signal != "" {
+		if err := validateSignal(signal); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 	tm := s.taskManager
 	containers, err := tm.getServiceContainers(service)
 	if err != nil {
@@ -124,7 +536,7 @@ func (s *Server) handleKillService(w http.ResponseWriter, r *http.Request) {
 	}
 	for _, container := range containers {
 		if err := tm.Cli.ContainerKill(tm.Ctx, container.ID, signal); err != nil {
-			log.Printf(`Failed to send signal to service "%s": %s\n`, service, err)
+			LogError("Failed to send signal to service", Fields{"service": service, "container_id": container.ID, "signal": signal, "error": err})
 			http.Error(w, "Server error", http.StatusInternalServerError)
 			return
 		}
@@ -146,7 +558,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *PublicServer) handleWs(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
+		LogError("Failed to upgrade websocket connection", Fields{"error": err})
 		http.Error(w, "WS connection failed", http.StatusInternalServerError)
 		return
 	}
@@ -160,7 +572,7 @@ func (s *PublicServer) handleWs(w http.ResponseWriter, r *http.Request) {
 func (s *PublicServer) broadcastJSON(data interface{}) {
 	json, err := json.Marshal(data)
 	if err != nil {
-		log.Println(err)
+		LogError("Failed to serialize JSON", Fields{"error": err})
 		return
 	}
 	s.hub.broadcast <- json
@@ -171,18 +583,27 @@ type taskNotificationMessage struct {
 	Task taskInfo `json:"task"`
 }
 
-func (s *PublicServer) taskStarted(task *Task) {
+func (s *PublicServer) taskStarted(task *Task, _ TaskStats) {
 	taskInfo := s.getTaskInfo(task)
 	msg := taskNotificationMessage{"TaskStarted", taskInfo}
 	s.broadcastJSON(msg)
 }
 
-func (s *PublicServer) taskFinished(task *Task) {
+func (s *PublicServer) taskFinished(task *Task, _ TaskStats) {
 	taskInfo := s.getTaskInfo(task)
 	msg := taskNotificationMessage{"TaskFinished", taskInfo}
 	s.broadcastJSON(msg)
 }
 
+type configReloadedMessage struct {
+	Type string     `json:"type"`
+	Diff ConfigDiff `json:"diff"`
+}
+
+func (s *PublicServer) configReloaded(diff ConfigDiff) {
+	s.broadcastJSON(configReloadedMessage{"ConfigReloaded", diff})
+}
+
 func indexHandler(webRoot string) func(w http.ResponseWriter, r *http.Request) {
 	indexHTML := filepath.Join(webRoot, "index.html")
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -195,11 +616,20 @@ func NewServer(taskManager *TaskManager) *Server {
 	router := chi.NewRouter()
 	s := Server{router, taskManager}
 	api := router.Group(nil)
-	api.Use(middleware.Logger)
+	api.Use(requestLogger)
 	api.HandleFunc("/api/tasks", s.handleTasksInfo)
+	api.Put("/api/tasks/{task}", s.handleTaskUpdate)
+	api.Delete("/api/tasks/{task}", s.handleTaskDelete)
+	api.Get("/api/tasks/{task}/config", s.handleTaskConfig)
+	api.Get("/api/tasks/{task}/upcoming", s.handleTaskUpcoming)
+	api.Get("/api/config", s.handleTasksConfig)
 	api.Post("/api/run/{task}", s.handleTaskRun)
 	api.Post("/api/services/kill/{service}", s.handleKillService)
+	api.Get("/api/schema", s.handleConfigSchema)
+	api.Get("/api/logs/search", s.handleLogsSearch)
 	api.HandleFunc("/api/logs/{task}/{id:[0-9]+}", s.handleTaskLogs)
+	api.Get("/api/tasks/{task}/runs/{id:[0-9]+}/artifacts", s.handleTaskArtifacts)
+	api.Get("/api/tasks/{task}/runs/{id:[0-9]+}/artifacts/*", s.handleTaskArtifact)
 	return &s
 }
 
@@ -216,16 +646,22 @@ func NewPublicServer(taskManager *TaskManager, webRoot string, auth AuthBackend)
 	s := PublicServer{Server{router, taskManager}, upgrader, wsHub}
 
 	api := router.Group(nil)
-	api.Use(middleware.Logger)
+	api.Use(requestLogger)
 
 	if auth != nil {
 		router.Post("/api/auth/login", auth.HandleLogin)
 		api.Use(auth.AuthMiddleware)
 	}
 
+	// configuration can be edited only through internal API server
 	api.HandleFunc("/api/tasks", s.handleTasksInfo)
+	api.Get("/api/tasks/{task}/upcoming", s.handleTaskUpcoming)
 	api.Post("/api/run/{task}", s.handleTaskRun)
+	api.Get("/api/logs/search", s.handleLogsSearch)
 	api.HandleFunc("/api/logs/{task}/{id:[0-9]+}", s.handleTaskLogs)
+	api.Get("/api/tasks/{task}/runs/{id:[0-9]+}/artifacts", s.handleTaskArtifacts)
+	api.Get("/api/tasks/{task}/runs/{id:[0-9]+}/artifacts/*", s.handleTaskArtifact)
+	api.Get("/api/schema", s.handleConfigSchema)
 	api.HandleFunc("/ws", s.handleWs)
 	router.Handle("/ui/static/*", http.StripPrefix("/ui/", http.FileServer(http.Dir(webRoot))))
 	router.HandleFunc("/ui", indexHandler(webRoot))
@@ -238,5 +674,6 @@ func NewPublicServer(taskManager *TaskManager, webRoot string, auth AuthBackend)
 
 	s.taskManager.AddTaskStartedListener(s.taskStarted)
 	s.taskManager.AddTaskFinishedListener(s.taskFinished)
+	s.taskManager.AddConfigReloadedListener(s.configReloaded)
 	return &s
 }
@@ -0,0 +1,111 @@
+package dcron
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidateTaskSecrets(t *testing.T) {
+	m := &TaskManager{Config: TasksConfig{
+		Secrets: map[string]secretDefinition{"db": {Environment: "DB_PASSWORD"}},
+	}}
+	tests := []struct {
+		name    string
+		secrets taskSecrets
+		err     string
+	}{
+		{"defined secret", taskSecrets{{Source: "db", Env: "DB_PASS"}}, ""},
+		{"undefined secret", taskSecrets{{Source: "api", Env: "API_KEY"}}, `undefined secret "api"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := runTask{Image: "alpine"}
+			task.Command = strSlice{"env"}
+			task.Secrets = test.secrets
+			errors := m.validateTask("x", taskDefinition{Run: &task})
+			if test.err == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 || !strings.Contains(errors[0].Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, errors)
+			}
+		})
+	}
+}
+
+func TestTaskConfigRedacted(t *testing.T) {
+	m := newTestTaskManager()
+	task := runTask{Image: "alpine"}
+	task.Environment = envMap{"DB_PASSWORD": "s3cret-db"}
+	task.Notifications = []webhookConfig{
+		{URL: "https://token@hooks.example.com/dcron", Headers: map[string]string{"Authorization": "Bearer s3cret-token"}},
+	}
+	m.Config.Run = map[string]runTask{"backup": task}
+	m.redactor.Set([]string{"alpine"})
+
+	rec := httptest.NewRecorder()
+	NewServer(m).router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/backup/config", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, body)
+	}
+	for _, secret := range []string{"s3cret", "token@", "alpine"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("%q is not redacted:\n%s", secret, body)
+		}
+	}
+	if !strings.Contains(body, "DB_PASSWORD") || !strings.Contains(body, "Authorization") {
+		t.Errorf("redacted keys missing:\n%s", body)
+	}
+}
+
+func TestTasksFilters(t *testing.T) {
+	m := newTestTaskManager()
+	m.Stats = &tasksStats{Tasks: make(map[string][]*TaskStats)}
+	config := testConfig("db", "nightly")
+	vacuum := execTask{Service: "db"}
+	vacuum.Tags = strSlice{"db"}
+	vacuum.Owner = "dba"
+	config.Exec["vacuum"] = vacuum
+	if _, err := m.LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(m)
+	tests := []struct {
+		query string
+		tasks string
+	}{
+		{"", "backup,cleanup,vacuum"},
+		{"?tag=db", "backup,vacuum"},
+		{"?tag=db&tag=nightly", "backup"},
+		{"?owner=dba", "vacuum"},
+		{"?tag=nightly&owner=dba", ""},
+		{"?tag=unknown", ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks"+test.query, nil))
+		var tasks map[string]taskInfo
+		if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		names := make([]string, 0, len(tasks))
+		for name := range tasks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if result := strings.Join(names, ","); result != test.tasks {
+			t.Errorf("GET /api/tasks%s: tasks %q, expected %q", test.query, result, test.tasks)
+		}
+	}
+	if info := s.getTaskInfo(m.Tasks["vacuum"]); info.Owner != "dba" || len(info.Tags) != 1 {
+		t.Errorf("metadata missing in task info: %+v", info)
+	}
+}
@@ -0,0 +1,400 @@
+package dcron
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// log sink types
+const (
+	sinkSyslog = "syslog"
+	sinkGELF   = "gelf"
+	sinkLoki   = "loki"
+)
+
+const (
+	sinkTimeout    = 5 * time.Second
+	sinkQueueSize  = 1000
+	lokiBatchSize  = 100
+	lokiBatchDelay = time.Second
+	// max size of GELF UDP datagram (chunk)
+	gelfChunkSize = 8192
+	gelfMaxChunks = 128
+)
+
+// sinkConfig log sink definition. Address is udp:// or tcp:// address for
+// syslog and GELF sinks, and URL of push API for Loki.
+type sinkConfig struct {
+	Type    string            `yaml:"type"`
+	Address string            `yaml:"address"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+}
+
+func (c sinkConfig) validate() error {
+	u, err := url.Parse(c.Address)
+	if c.Address == "" || err != nil {
+		return fmt.Errorf("invalid address %q", c.Address)
+	}
+	switch c.Type {
+	case sinkSyslog, sinkGELF:
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return fmt.Errorf("%s sink address must be udp:// or tcp:// address", c.Type)
+		}
+	case sinkLoki:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("loki sink address must be http:// or https:// URL")
+		}
+	default:
+		return fmt.Errorf("invalid sink type %q (syslog, gelf or loki)", c.Type)
+	}
+	return nil
+}
+
+// sinkEntry line of task output sent to log sinks
+type sinkEntry struct {
+	Task   string
+	RunID  int
+	Stream string
+	Time   time.Time
+	Line   string
+}
+
+// logSink destination of tasks output
+type logSink interface {
+	Send(entry sinkEntry) error
+	Close() error
+}
+
+var hostname, _ = os.Hostname()
+
+func dialSink(address string) (net.Conn, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout(u.Scheme, u.Host, sinkTimeout)
+}
+
+// syslogSink sends entries as RFC5424 messages (octet counting framing over TCP)
+type syslogSink struct {
+	conn   net.Conn
+	tcp    bool
+	params string
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogName value of header field (printable ASCII, limited length)
+func syslogName(value string, max int) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(name) > max {
+		name = name[:max]
+	}
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+func (s *syslogSink) Send(e sinkEntry) error {
+	// facility user, severity informational or error
+	priority := 1*8 + 6
+	if e.Stream == "stderr" {
+		priority = 1*8 + 3
+	}
+	msg := fmt.Sprintf(`<%d>1 %s %s dcron %d %s [dcron@32473 task="%s" run_id="%d" stream="%s"%s] %s`,
+		priority, e.Time.Format(time.RFC3339Nano), syslogName(hostname, 255), e.RunID,
+		syslogName(e.Stream, 32), sdEscaper.Replace(e.Task), e.RunID, e.Stream, s.params, e.Line)
+	if s.tcp {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// gelfSink sends entries as GELF messages (compressed and chunked over UDP,
+// null byte delimited over TCP)
+type gelfSink struct {
+	conn   net.Conn
+	tcp    bool
+	labels map[string]string
+}
+
+func (s *gelfSink) Send(e sinkEntry) error {
+	level := 6
+	if e.Stream == "stderr" {
+		level = 3
+	}
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          hostname,
+		"short_message": e.Line,
+		"timestamp":     float64(e.Time.UnixNano()) / 1e9,
+		"level":         level,
+		"_task":         e.Task,
+		"_run_id":       e.RunID,
+		"_stream":       e.Stream,
+	}
+	for key, value := range s.labels {
+		msg["_"+key] = value
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+	if s.tcp {
+		_, err = s.conn.Write(append(data, 0))
+		return err
+	}
+	if len(data) > gelfChunkSize {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		data = buf.Bytes()
+	}
+	return s.writeChunks(data)
+}
+
+func (s *gelfSink) writeChunks(data []byte) error {
+	if len(data) <= gelfChunkSize {
+		_, err := s.conn.Write(data)
+		return err
+	}
+	const headerSize = 12
+	size := gelfChunkSize - headerSize
+	count := (len(data) + size - 1) / size
+	if count > gelfMaxChunks {
+		return fmt.Errorf("GELF message is too large")
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := append([]byte{0x1e, 0x0f}, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		if _, err := s.conn.Write(append(chunk, data[i*size:end]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *gelfSink) Close() error {
+	return s.conn.Close()
+}
+
+// lokiSink pushes entries in batches to Loki push API
+type lokiSink struct {
+	task      string
+	url       string
+	labels    map[string]string
+	client    *http.Client
+	batch     map[string][][]interface{}
+	size      int
+	lastFlush time.Time
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][]interface{}   `json:"values"`
+}
+
+func (s *lokiSink) Send(e sinkEntry) error {
+	s.task = e.Task
+	key := e.Stream
+	// run ID is sent as structured metadata, labels of each run would create new streams
+	metadata := map[string]string{"run_id": strconv.Itoa(e.RunID)}
+	s.batch[key] = append(s.batch[key], []interface{}{strconv.FormatInt(e.Time.UnixNano(), 10), e.Line, metadata})
+	s.size++
+	if s.size >= lokiBatchSize || time.Since(s.lastFlush) > lokiBatchDelay {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *lokiSink) flush() error {
+	s.lastFlush = time.Now()
+	if s.size == 0 {
+		return nil
+	}
+	var streams []lokiStream
+	for stream, values := range s.batch {
+		labels := map[string]string{"task": s.task, "stream": stream}
+		for key, value := range s.labels {
+			labels[key] = value
+		}
+		streams = append(streams, lokiStream{labels, values})
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Stream["stream"] < streams[j].Stream["stream"] })
+	s.batch = make(map[string][][]interface{})
+	s.size = 0
+	data, err := json.Marshal(map[string]interface{}{"streams": streams})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("push failed with status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *lokiSink) Close() error {
+	return s.flush()
+}
+
+func newLogSink(c sinkConfig) (logSink, error) {
+	switch c.Type {
+	case sinkSyslog:
+		conn, err := dialSink(c.Address)
+		if err != nil {
+			return nil, err
+		}
+		// labels are added as structured data parameters
+		var params []string
+		for key, value := range c.Labels {
+			name := strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(syslogName(key, 32))
+			params = append(params, fmt.Sprintf(` %s="%s"`, name, sdEscaper.Replace(value)))
+		}
+		sort.Strings(params)
+		return &syslogSink{conn, strings.HasPrefix(c.Address, "tcp:"), strings.Join(params, "")}, nil
+	case sinkGELF:
+		conn, err := dialSink(c.Address)
+		if err != nil {
+			return nil, err
+		}
+		return &gelfSink{conn, strings.HasPrefix(c.Address, "tcp:"), c.Labels}, nil
+	case sinkLoki:
+		return &lokiSink{
+			url:       c.Address,
+			labels:    c.Labels,
+			client:    &http.Client{Timeout: sinkTimeout},
+			batch:     make(map[string][][]interface{}),
+			lastFlush: time.Now(),
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid sink type %q", c.Type)
+}
+
+// runSinks log sinks of a task run, entries are sent in background
+// (and dropped when sinks can't keep up)
+type runSinks struct {
+	task    string
+	runID   int
+	entries chan sinkEntry
+	done    chan struct{}
+	dropped int
+}
+
+func (m *TaskManager) logSinks(task string) []sinkConfig {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if t, ok := m.Tasks[task]; ok && len(t.logs.Sinks) > 0 {
+		return t.logs.Sinks
+	}
+	return m.Config.Logs.Sinks
+}
+
+// startSinks opens configured log sinks for the task run (nil if there are none)
+func (m *TaskManager) startSinks(task string, runID int) *runSinks {
+	configs := m.logSinks(task)
+	if len(configs) == 0 {
+		return nil
+	}
+	s := &runSinks{
+		task:    task,
+		runID:   runID,
+		entries: make(chan sinkEntry, sinkQueueSize),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		var sinks []logSink
+		var types []string
+		for _, c := range configs {
+			sink, err := newLogSink(c)
+			if err != nil {
+				LogError("Failed to open log sink", Fields{"task": task, "run_id": runID, "sink": c.Type, "error": err})
+				continue
+			}
+			sinks = append(sinks, sink)
+			types = append(types, c.Type)
+		}
+		failed := make([]bool, len(sinks))
+		for entry := range s.entries {
+			for i, sink := range sinks {
+				if failed[i] {
+					continue
+				}
+				if err := sink.Send(entry); err != nil {
+					LogError("Failed to send logs to log sink", Fields{"task": task, "run_id": runID, "sink": types[i], "error": err})
+					failed[i] = true
+				}
+			}
+		}
+		for i, sink := range sinks {
+			if err := sink.Close(); err != nil && !failed[i] {
+				LogError("Failed to send logs to log sink", Fields{"task": task, "run_id": runID, "sink": types[i], "error": err})
+			}
+		}
+	}()
+	return s
+}
+
+func (s *runSinks) write(stream string, t time.Time, p []byte) {
+	if s == nil {
+		return
+	}
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		select {
+		case s.entries <- sinkEntry{s.task, s.runID, stream, t, strings.TrimSuffix(line, "\n")}:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// Close waits until all entries are sent and closes sinks
+func (s *runSinks) Close() {
+	if s == nil {
+		return
+	}
+	close(s.entries)
+	<-s.done
+	if s.dropped > 0 {
+		LogWarn("Dropped log entries not sent to log sinks", Fields{"task": s.task, "run_id": s.runID, "count": s.dropped})
+	}
+}
@@ -0,0 +1,49 @@
+package dcron
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLokiSinkLabels(t *testing.T) {
+	var pushed struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"streams"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&pushed); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := newLogSink(sinkConfig{Type: sinkLoki, Address: server.URL, Labels: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Unix(1600000000, 0)
+	sink.Send(sinkEntry{Task: "backup", RunID: 7, Stream: "stdout", Time: at, Line: "done"})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pushed.Streams) != 1 {
+		t.Fatalf("unexpected streams: %+v", pushed.Streams)
+	}
+	stream := pushed.Streams[0]
+	labels := map[string]string{"task": "backup", "stream": "stdout", "env": "prod"}
+	if !reflect.DeepEqual(stream.Stream, labels) {
+		t.Errorf("labels %v, expected %v", stream.Stream, labels)
+	}
+	value := []interface{}{"1600000000000000000", "done", map[string]interface{}{"run_id": "7"}}
+	if len(stream.Values) != 1 || !reflect.DeepEqual(stream.Values[0], value) {
+		t.Errorf("values %v, expected %v", stream.Values, []interface{}{value})
+	}
+}
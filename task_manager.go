@@ -5,9 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -34,57 +35,166 @@ func (e *strSlice) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	case []interface{}:
 		array := make([]string, len(cmd))
 		for i, item := range cmd {
-			array[i] = item.(string)
+			str, ok := item.(string)
+			if !ok {
+				return unmarshalError("Unsupported item type: %v", item)
+			}
+			array[i] = str
 		}
 		*e = array
 	default:
-		return fmt.Errorf("Unsupported type")
+		return unmarshalError("Unsupported type %T", value)
+	}
+	return nil
+}
+
+type envMap map[string]string
+
+func (e *envMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	env := make(envMap)
+	switch vars := value.(type) {
+	case []interface{}:
+		for _, item := range vars {
+			str, ok := item.(string)
+			if !ok {
+				return unmarshalError("Unsupported item type: %v", item)
+			}
+			parts := strings.SplitN(str, "=", 2)
+			if len(parts) == 2 {
+				env[parts[0]] = parts[1]
+			} else {
+				// value from dcron's environment
+				env[parts[0]] = os.Getenv(parts[0])
+			}
+		}
+	case map[interface{}]interface{}:
+		for key, val := range vars {
+			if val == nil {
+				val = ""
+			}
+			env[fmt.Sprint(key)] = fmt.Sprint(val)
+		}
+	case nil:
+	default:
+		return unmarshalError("Unsupported type %T", value)
 	}
+	*e = env
 	return nil
 }
 
+// List returns variables in KEY=value format
+func (e envMap) List() []string {
+	list := make([]string, 0, len(e))
+	for key, value := range e {
+		list = append(list, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(list)
+	return list
+}
+
 type baseTask struct {
-	Schedule string   `yaml:"schedule"`
-	Command  strSlice `yaml:"command"`
+	Description string              `yaml:"description,omitempty"`
+	Tags        strSlice            `yaml:"tags,omitempty"`
+	Owner       string              `yaml:"owner,omitempty"`
+	Schedule    string              `yaml:"schedule,omitempty"`
+	Command     strSlice            `yaml:"command,omitempty"`
+	Environment envMap              `yaml:"environment,omitempty"`
+	Catchup     string              `yaml:"catchup,omitempty"`
+	Matrix      map[string][]string `yaml:"matrix,omitempty"`
+	Secrets     taskSecrets         `yaml:"secrets,omitempty"`
+	Logs        logsConfig          `yaml:"logs,omitempty"`
+	// mirror output to dcron's stdout/stderr (default true)
+	MirrorOutput  *bool           `yaml:"mirror_output,omitempty"`
+	Notifications []webhookConfig `yaml:"notifications,omitempty"`
+	// name of the matrix task and values of the expanded task
+	group        string
+	matrixValues map[string]string
 }
 
 type runTask struct {
 	baseTask    `yaml:",inline"`
-	Image       string   `yaml:"image"`
-	Volumes     []string `yaml:"volumes,flow"`
-	NetworkMode string   `yaml:"network_mode"`
-	Entrypoint  strSlice `yaml:"entrypoint"`
+	Image       string                 `yaml:"image,omitempty"`
+	Volumes     []string               `yaml:"volumes,flow,omitempty"`
+	NetworkMode string                 `yaml:"network_mode,omitempty"`
+	Entrypoint  strSlice               `yaml:"entrypoint,omitempty"`
+	Artifacts   []string               `yaml:"artifacts,flow,omitempty"`
+	Timeout     string                 `yaml:"timeout,omitempty"`
+	StopSignal  string                 `yaml:"stop_signal,omitempty"`
+	Extensions  map[string]interface{} `yaml:",inline"`
 }
 
 type execTask struct {
-	baseTask `yaml:",inline"`
-	Service  string `yaml:"service"`
-	User     string `yaml:"user"`
+	baseTask   `yaml:",inline"`
+	Service    string                 `yaml:"service,omitempty"`
+	User       string                 `yaml:"user,omitempty"`
+	Extensions map[string]interface{} `yaml:",inline"`
+}
+
+type tasksDefaults struct {
+	Run  runTask  `yaml:"run,omitempty"`
+	Exec execTask `yaml:"exec,omitempty"`
 }
 
 // TasksConfig tasks definitions
 type TasksConfig struct {
-	Run  map[string]runTask
-	Exec map[string]execTask
+	Defaults      tasksDefaults               `yaml:"defaults,omitempty"`
+	Secrets       map[string]secretDefinition `yaml:"secrets,omitempty"`
+	Logs          logsConfig                  `yaml:"logs,omitempty"`
+	Notifications []webhookConfig             `yaml:"notifications,omitempty"`
+	Run           map[string]runTask
+	Exec          map[string]execTask
+	Extensions    map[string]interface{} `yaml:",inline"`
 }
 
 // Logger interface for docker tasks
 type Logger interface {
 	StdoutWriter() io.Writer
 	StderrWriter() io.Writer
+	// fields of the task run for dcron's log messages
+	Fields() Fields
+	// directory for artifacts of the task run
+	ArtifactsDir() string
+	// size limit of artifacts of the task run (0 for unlimited)
+	ArtifactsLimit() int64
+	// redacts values of secrets used by the task run until it finishes
+	HoldSecrets(values ...string)
 }
 
-// Task definition
+// Task definition, fields except EntryID (guarded by TaskManager's mutex)
+// are not modified after the task is created
 type Task struct {
-	Name     string
-	Schedule string
-	Run      func(l Logger) (int, error)
-	EntryID  cron.EntryID
+	Name          string
+	Description   string
+	Tags          []string
+	Owner         string
+	Schedule      string
+	Run           func(l Logger) (int, error)
+	EntryID       cron.EntryID
+	Catchup       string
+	Group         string
+	Matrix        map[string]string
+	logs          logsConfig
+	mirror        bool
+	notifications []webhookConfig
+	config        interface{}
 }
 
 type taskListeners struct {
-	Started  []func(*Task)
-	Finished []func(*Task)
+	Started  []func(*Task, TaskStats)
+	Finished []func(*Task, TaskStats)
+	Reloaded []func(ConfigDiff)
+}
+
+// ConfigDiff tasks changed by configuration reload
+type ConfigDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Changed   []string `json:"changed"`
+	Unchanged []string `json:"unchanged"`
 }
 
 // TaskStats stats about run task
@@ -96,11 +206,18 @@ type TaskStats struct {
 	Status     int       `json:"status"`
 	StdoutSize int       `json:"stdout_size"`
 	StderrSize int       `json:"stderr_size"`
+	CatchUp    bool      `json:"catchup"`
+	Error      string    `json:"error,omitempty"`
+	Truncated  bool      `json:"truncated,omitempty"`
+	TimedOut   bool      `json:"timed_out,omitempty"`
+	Duration   float64   `json:"duration,omitempty"`
 }
 
 type tasksStats struct {
 	sync.RWMutex
 	Tasks map[string][]*TaskStats
+	// last run IDs of tasks
+	lastIDs map[string]int
 }
 
 // TaskManager export
@@ -109,19 +226,26 @@ type TaskManager struct {
 	Cli         *client.Client
 	Cron        *cron.Cron
 	ProjectName string
+	Compose     *ComposeProject
 	Tasks       map[string]*Task
 	Config      TasksConfig
+	ConfigStore *ConfigStore
 	Stats       *tasksStats
 	LogsRoot    string
 	running     bool
 	listeners   taskListeners
+	state       *scheduleState
+	redactor    *redactor
+	stopPrune   chan struct{}
+	pruneMutex  sync.Mutex
+	mutex       sync.RWMutex
 }
 
 // NewTaskManager export
 func NewTaskManager(config TasksConfig, project, logsDir string) (*TaskManager, error) {
 	ctx := context.Background()
 	defaultHeaders := map[string]string{"User-Agent": "engine-api-cli-1.0"}
-	cli, err := client.NewClient("unix:///var/run/docker.sock", "v1.22", nil, defaultHeaders)
+	cli, err := client.NewClient("unix:///var/run/docker.sock", "v1.25", nil, defaultHeaders)
 	if err != nil {
 		return nil, err
 	}
@@ -135,23 +259,68 @@ func NewTaskManager(config TasksConfig, project, logsDir string) (*TaskManager,
 		Ctx:         ctx,
 		Cli:         cli,
 		Cron:        c,
+		Tasks:       make(map[string]*Task),
 		LogsRoot:    logsDir,
+		state:       loadScheduleState(filepath.Join(logsDir, "schedule.json")),
+		redactor:    &redactor{},
 	}
 	tm.listeners = taskListeners{}
-	tm.LoadConfig(config)
+	tm.AddTaskStartedListener(tm.notifyStarted)
+	tm.AddTaskFinishedListener(tm.notifyFinished)
+	if _, err := tm.LoadConfig(config); err != nil {
+		return nil, err
+	}
 	return &tm, nil
 }
 
-// AddTaskStartedListener register listener for started tasks events
-func (m *TaskManager) AddTaskStartedListener(listener func(*Task)) {
+// AddTaskStartedListener register listener for started tasks events,
+// listeners receive stats of the started run
+func (m *TaskManager) AddTaskStartedListener(listener func(*Task, TaskStats)) {
 	m.listeners.Started = append(m.listeners.Started, listener)
 }
 
-// AddTaskFinishedListener register listener for finished tasks events
-func (m *TaskManager) AddTaskFinishedListener(listener func(*Task)) {
+// AddTaskFinishedListener register listener for finished tasks events,
+// listeners receive stats of the finished run
+func (m *TaskManager) AddTaskFinishedListener(listener func(*Task, TaskStats)) {
 	m.listeners.Finished = append(m.listeners.Finished, listener)
 }
 
+// AddConfigReloadedListener register listener for configuration reload events
+func (m *TaskManager) AddConfigReloadedListener(listener func(ConfigDiff)) {
+	m.listeners.Reloaded = append(m.listeners.Reloaded, listener)
+}
+
+// GetTask returns task by its name
+func (m *TaskManager) GetTask(name string) (*Task, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	task, ok := m.Tasks[name]
+	return task, ok
+}
+
+// hasTask reports whether task or matrix task (group of tasks) of the name exists
+func (m *TaskManager) hasTask(name string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, task := range m.Tasks {
+		if task.Name == name || task.Group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TasksList returns all configured tasks
+func (m *TaskManager) TasksList() []*Task {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	tasks := make([]*Task, 0, len(m.Tasks))
+	for _, task := range m.Tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
 func (m *TaskManager) runTaskFunction(config runTask) func(Logger) (int, error) {
 	return func(l Logger) (int, error) {
 		return m.runDockerCommand(l, config)
@@ -164,28 +333,106 @@ func (m *TaskManager) execTaskFunction(config execTask) func(Logger) (int, error
 	}
 }
 
+func newTask(name string, conf baseTask, run func(Logger) (int, error), config interface{}) *Task {
+	return &Task{
+		Name:          name,
+		Description:   conf.Description,
+		Tags:          conf.Tags,
+		Owner:         conf.Owner,
+		Schedule:      conf.Schedule,
+		Run:           run,
+		EntryID:       -1,
+		Catchup:       conf.Catchup,
+		Group:         conf.group,
+		Matrix:        conf.matrixValues,
+		logs:          conf.Logs,
+		mirror:        conf.MirrorOutput == nil || *conf.MirrorOutput,
+		notifications: conf.Notifications,
+		config:        config,
+	}
+}
+
 func (m *TaskManager) cronTask(task *Task) func() {
 	return func() {
+		m.state.Set(task.Name, time.Now())
 		m.RunTask(task)
 	}
 }
 
-// LoadConfig load tasks configuration (without starting)
-func (m *TaskManager) LoadConfig(config TasksConfig) {
+func (m *TaskManager) schedule(task *Task) error {
+	if task.Schedule == "" || task.EntryID > 0 {
+		return nil
+	}
+	id, err := m.Cron.AddFunc(task.Schedule, m.cronTask(task))
+	if err != nil {
+		return err
+	}
+	task.EntryID = id
+	return nil
+}
+
+func (m *TaskManager) unschedule(task *Task) {
+	if task.EntryID > 0 {
+		m.Cron.Remove(task.EntryID)
+		task.EntryID = -1
+	}
+}
+
+// LoadConfig load tasks configuration. Only added, removed and changed
+// tasks are (re)scheduled, running tasks are not affected. Configuration
+// with run and exec tasks of the same name is rejected.
+func (m *TaskManager) LoadConfig(config TasksConfig) (ConfigDiff, error) {
+	if err := config.checkTaskNames(); err != nil {
+		return ConfigDiff{}, err
+	}
 	tasks := make(map[string]*Task)
 	for name, task := range config.Run {
-		tasks[name] = &Task{name, task.Schedule, m.runTaskFunction(task), -1}
+		tasks[name] = newTask(name, task.baseTask, m.runTaskFunction(task), task)
 	}
 	for name, task := range config.Exec {
-		tasks[name] = &Task{name, task.Schedule, m.execTaskFunction(task), -1}
+		tasks[name] = newTask(name, task.baseTask, m.execTaskFunction(task), task)
+	}
+	m.loadSecrets(config.Secrets)
+
+	m.mutex.Lock()
+	diff := ConfigDiff{}
+	for name, task := range tasks {
+		current, ok := m.Tasks[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+		} else if reflect.DeepEqual(current.config, task.config) {
+			diff.Unchanged = append(diff.Unchanged, name)
+			tasks[name] = current
+			continue
+		} else {
+			// changed task is replaced, in-flight runs keep the previous definition
+			diff.Changed = append(diff.Changed, name)
+			m.unschedule(current)
+		}
+		if m.running {
+			if err := m.schedule(task); err != nil {
+				LogError("Failed to schedule task", Fields{"task": name, "error": err})
+			}
+		}
+	}
+	for name, task := range m.Tasks {
+		if _, ok := tasks[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+			m.unschedule(task)
+		}
 	}
-	m.Cron = cron.New()
 	m.Tasks = tasks
 	m.Config = config
-	// if m.running {
-	// 	m.Stop()
-	// 	m.Start()
-	// }
+	m.mutex.Unlock()
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Unchanged)
+	for _, listener := range m.listeners.Reloaded {
+		listener(diff)
+	}
+	return diff, nil
 }
 
 func (m *TaskManager) containerName(name string) string {
@@ -195,12 +442,51 @@ func (m *TaskManager) containerName(name string) string {
 	return name
 }
 
+func (m *TaskManager) volumeName(name string) string {
+	if m.Compose != nil {
+		if volume, ok := m.Compose.Volumes[name]; ok {
+			return volume
+		}
+	}
+	return m.containerName(name)
+}
+
+func (m *TaskManager) networkName(name string) string {
+	key := name
+	if key == "" {
+		// default network can be customized in compose file (e.g. external network)
+		key = "default"
+	}
+	if m.Compose != nil {
+		if network, ok := m.Compose.Networks[key]; ok {
+			return network
+		}
+	}
+	if name == "" {
+		return fmt.Sprintf("%s_default", m.ProjectName)
+	}
+	return name
+}
+
 func (m *TaskManager) runDockerCommand(logger Logger, conf runTask) (int, error) {
+	var timeout time.Duration
+	if conf.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+			return -1, fmt.Errorf("Invalid timeout: %s", err)
+		}
+	}
+	secretsEnv, secretFiles, err := m.resolveSecrets(logger, conf.Secrets)
+	if err != nil {
+		return -1, err
+	}
 	config := &container.Config{
 		Image:        conf.Image,
 		Cmd:          []string(conf.Command),
+		Env:          append(conf.Environment.List(), secretsEnv...),
 		AttachStderr: true,
 		AttachStdout: true,
+		StopSignal:   conf.StopSignal,
 	}
 	if conf.Entrypoint != nil {
 		config.Entrypoint = []string(conf.Entrypoint)
@@ -208,17 +494,15 @@ func (m *TaskManager) runDockerCommand(logger Logger, conf runTask) (int, error)
 
 	binds := make([]string, 0)
 	for _, item := range conf.Volumes {
-		src := strings.Split(item, ":")[0]
-		if strings.Contains(src, "/") {
+		parts := strings.SplitN(item, ":", 2)
+		if strings.Contains(parts[0], "/") {
 			binds = append(binds, item)
 		} else {
-			binds = append(binds, m.containerName(item))
+			parts[0] = m.volumeName(parts[0])
+			binds = append(binds, strings.Join(parts, ":"))
 		}
 	}
-	network := conf.NetworkMode
-	if network == "" {
-		network = fmt.Sprintf("%s_default", m.ProjectName)
-	}
+	network := m.networkName(conf.NetworkMode)
 	hostConfig := &container.HostConfig{
 		Binds:       binds,
 		NetworkMode: container.NetworkMode(network),
@@ -227,32 +511,80 @@ func (m *TaskManager) runDockerCommand(logger Logger, conf runTask) (int, error)
 	if err != nil {
 		return -1, err
 	}
+	fields := logger.Fields().With("container_id", resp.ID)
+	LogDebug("Container created", fields.With("image", conf.Image))
+	if len(secretFiles) > 0 {
+		archive, err := secretsArchive(secretFiles)
+		if err == nil {
+			err = m.Cli.CopyToContainer(m.Ctx, resp.ID, "/", archive, types.CopyToContainerOptions{})
+		}
+		if err != nil {
+			m.Cli.ContainerRemove(m.Ctx, resp.ID, types.ContainerRemoveOptions{})
+			return -1, fmt.Errorf("Failed to copy secrets to container: %s", err)
+		}
+	}
 	if err := m.Cli.ContainerStart(m.Ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
 		return -1, err
 	}
-	status, err := m.Cli.ContainerWait(m.Ctx, resp.ID)
+	waitCtx := m.Ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(m.Ctx, timeout)
+		defer cancel()
+	}
+	var runErr error
+	status, err := m.Cli.ContainerWait(waitCtx, resp.ID)
+	if err != nil && waitCtx.Err() == context.DeadlineExceeded {
+		LogWarn("Task timed out, killing container", fields.With("timeout", timeout))
+		runErr = timeoutError(timeout)
+		if conf.StopSignal != "" {
+			// stop signal first, SIGKILL after the grace period
+			grace := stopGracePeriod
+			err = m.Cli.ContainerStop(m.Ctx, resp.ID, &grace)
+		} else {
+			err = m.Cli.ContainerKill(m.Ctx, resp.ID, "SIGKILL")
+		}
+		if err != nil {
+			LogError("Failed to kill container", fields.With("error", err))
+		}
+		status, err = m.Cli.ContainerWait(m.Ctx, resp.ID)
+	}
 	if err != nil {
 		return -1, err
 	}
-	out, err := m.Cli.ContainerLogs(m.Ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	out, err := m.Cli.ContainerLogs(m.Ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true})
 	if err != nil {
 		return -1, err
 	}
 	defer out.Close()
 
-	if _, err := stdcopy.StdCopy(logger.StdoutWriter(), logger.StderrWriter(), out); err != nil {
-		log.Printf("Failed to log task output: %s\n", err)
+	stdout := newTimestampsWriter(logger.StdoutWriter())
+	stderr := newTimestampsWriter(logger.StderrWriter())
+	if _, err := stdcopy.StdCopy(stdout, stderr, out); err != nil {
+		LogError("Failed to log task output", fields.With("error", err))
+	}
+	stdout.Flush()
+	stderr.Flush()
+	if len(conf.Artifacts) > 0 {
+		m.collectArtifacts(logger, resp.ID, conf.Artifacts)
 	}
 	if err := m.Cli.ContainerRemove(m.Ctx, resp.ID, types.ContainerRemoveOptions{}); err != nil {
-		log.Printf("Failed to remove container: %s\n", resp.ID)
+		LogError("Failed to remove container", fields.With("error", err))
+	} else {
+		LogDebug("Container removed", fields)
 	}
-	return int(status), nil
+	return int(status), runErr
 }
 
 func (m *TaskManager) getServiceContainers(name string) ([]types.Container, error) {
 	list := make([]types.Container, 0)
 	query := filters.NewArgs()
 	query.Add("status", "running")
+	if m.Compose != nil {
+		query.Add("label", fmt.Sprintf("com.docker.compose.project=%s", m.Compose.Name))
+		query.Add("label", fmt.Sprintf("com.docker.compose.service=%s", name))
+		return m.Cli.ContainerList(m.Ctx, types.ContainerListOptions{Filters: query})
+	}
 	containers, err := m.Cli.ContainerList(m.Ctx, types.ContainerListOptions{Filters: query})
 	if err != nil {
 		return list, err
@@ -267,6 +599,13 @@ func (m *TaskManager) getServiceContainers(name string) ([]types.Container, erro
 }
 
 func (m *TaskManager) execDockerCommand(logger Logger, conf execTask) (int, error) {
+	secretsEnv, secretFiles, err := m.resolveSecrets(logger, conf.Secrets)
+	if err != nil {
+		return -1, err
+	}
+	if len(secretFiles) > 0 {
+		return -1, fmt.Errorf("Secrets of exec tasks can be passed only as environment variables")
+	}
 	containers, err := m.getServiceContainers(conf.Service)
 	if err != nil {
 		return -1, err
@@ -275,6 +614,7 @@ func (m *TaskManager) execDockerCommand(logger Logger, conf execTask) (int, erro
 		config := types.ExecConfig{
 			User:         conf.User,
 			Cmd:          conf.Command,
+			Env:          append(conf.Environment.List(), secretsEnv...),
 			AttachStdout: true,
 			AttachStderr: true,
 			Tty:          false,
@@ -292,8 +632,10 @@ func (m *TaskManager) execDockerCommand(logger Logger, conf execTask) (int, erro
 		if err := m.Cli.ContainerExecStart(m.Ctx, resp.ID, types.ExecStartCheck{}); err != nil {
 			return -1, err
 		}
+		fields := logger.Fields().With("container_id", container.ID).With("exec_id", resp.ID)
+		LogDebug("Command started in container", fields)
 		if _, err := stdcopy.StdCopy(logger.StdoutWriter(), logger.StderrWriter(), atinfo.Reader); err != nil {
-			log.Printf("Failed to log task output: %s\n", err)
+			LogError("Failed to log task output", fields.With("error", err))
 		}
 		inspect, err := m.Cli.ContainerExecInspect(m.Ctx, resp.ID)
 		if err != nil {
@@ -304,6 +646,16 @@ func (m *TaskManager) execDockerCommand(logger Logger, conf execTask) (int, erro
 	return -1, fmt.Errorf("Running service not found: %s", conf.Service)
 }
 
+// stopGracePeriod time between stop signal and SIGKILL of timed out run task
+const stopGracePeriod = 10 * time.Second
+
+// timeoutError error of run task killed after its timeout
+type timeoutError time.Duration
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("Task timed out after %s", time.Duration(e))
+}
+
 // GetLogfilePath location of logfile
 func (m *TaskManager) GetLogfilePath(task string, id int) string {
 	logfilename := fmt.Sprintf("%s.%d.log", task, id)
@@ -325,60 +677,96 @@ func (m *TaskManager) isTaskRunning(task string) bool {
 
 // RunTask execute task
 func (m *TaskManager) RunTask(task *Task) {
+	m.runTask(task, false)
+}
+
+func (m *TaskManager) runTask(task *Task, catchUp bool) {
 	startTime := time.Now()
-	log.Printf("[CRON] (%s) Start\n", task.Name)
 	m.Stats.Lock()
 	statsEntry := &TaskStats{
 		StartTime: startTime,
 		Running:   true,
 		Status:    -1,
+		CatchUp:   catchUp,
 	}
-	if taskStats, ok := m.Stats.Tasks[task.Name]; ok {
-		last := taskStats[len(taskStats)-1]
-		statsEntry.ID = last.ID + 1
-		taskStats = append(taskStats, statsEntry)
-		m.Stats.Tasks[task.Name] = taskStats
-	} else {
-		newStats := make([]*TaskStats, 1)
-		statsEntry.ID = 1
-		newStats[0] = statsEntry
-		m.Stats.Tasks[task.Name] = newStats
-	}
+	statsEntry.ID = m.nextRunID(task.Name)
+	m.Stats.Tasks[task.Name] = append(m.Stats.Tasks[task.Name], statsEntry)
 	logfile := m.GetLogfilePath(task.Name, statsEntry.ID)
+	started := *statsEntry
 	m.Stats.Unlock()
+	fields := Fields{"task": task.Name, "run_id": statsEntry.ID}
+	if catchUp {
+		fields["catchup"] = true
+	}
+	LogInfo("Task started", fields)
 	f, err := os.Create(logfile)
 	if err != nil {
-		log.Printf("Failed to create logfile %s: %s\n", logfile, err)
+		LogError("Failed to create log file", fields.With("path", logfile).With("error", err))
 		return
 	}
 	defer f.Close()
 	for _, listener := range m.listeners.Started {
-		listener(task)
+		listener(task, started)
 	}
 
 	logWriter := bufio.NewWriter(f)
-	logger := newDockerLogger(logWriter)
+	sinks := m.startSinks(task.Name, statsEntry.ID)
+	logger := newDockerLogger(logWriter, m.redactor, int(m.maxLogSize(task.Name)), sinks)
+	logger.fields = fields
+	logger.artifactsDir = m.GetArtifactsPath(task.Name, statsEntry.ID)
+	logger.artifactsLimit = int64(m.maxArtifactsSize(task.Name))
+	logger.mirror(fmt.Sprintf("[%s#%d] ", task.Name, statsEntry.ID), task.mirror)
 	status, err := task.Run(logger)
 	m.Stats.Lock()
-	if err != nil {
-		log.Printf("[CRON] (%s) Error: %s\n", task.Name, err)
+	if _, ok := err.(timeoutError); ok {
+		LogWarn("Task timed out", fields.With("status", status).With("duration", time.Since(startTime)))
+		fmt.Fprintf(logger.StderrWriter(), "[CRON] %s\n", err)
+		statsEntry.TimedOut = true
+		statsEntry.Status = status
+		statsEntry.Error = err.Error()
+	} else if err != nil {
+		LogError("Task failed", fields.With("error", m.redactor.Redact(err.Error())).With("duration", time.Since(startTime)))
 		fmt.Fprintf(logger.StderrWriter(), "[CRON] Error: %s\n", err)
 		statsEntry.Crashed = true
+		statsEntry.Error = m.redactor.Redact(err.Error())
 	} else {
 		statsEntry.Status = status
 	}
+	logger.flushOutput()
+	logger.Log.Flush()
+	logger.flushMirror()
 	logWriter.Flush()
+	statsEntry.Truncated = logger.Log.Truncated()
 	statsEntry.StdoutSize = logger.stdout.Size
 	statsEntry.StderrSize = logger.stderr.Size
+	logger.releaseSecrets()
 
 	statsEntry.Running = false
+	statsEntry.Duration = time.Since(startTime).Seconds()
+	finished := *statsEntry
 	m.Stats.Unlock()
-	log.Printf("[CRON] (%s) Status: %d\n", task.Name, status)
+	sinks.Close()
+	if err == nil {
+		fields = fields.With("status", status).With("duration", time.Since(startTime))
+		if status == 0 {
+			LogInfo("Task finished", fields)
+		} else {
+			LogWarn("Task finished with non-zero status", fields)
+		}
+	}
+	go func() {
+		if age, ok := m.logCompression(task.Name).age(); ok && age == 0 {
+			if err := compressLog(logfile); err != nil {
+				LogError("Failed to compress log file", fields.With("path", logfile).With("error", err))
+			}
+		}
+		m.PruneLogs()
+	}()
 
 	go func() {
 		time.Sleep(50 * time.Millisecond)
 		for _, listener := range m.listeners.Finished {
-			listener(task)
+			listener(task, finished)
 		}
 	}()
 }
@@ -389,22 +777,43 @@ func (m *TaskManager) Start() error {
 		stats := &tasksStats{Tasks: make(map[string][]*TaskStats, len(m.Tasks))}
 		m.Stats = stats
 	}
+	now := time.Now()
+	missed := make(map[*Task]int)
+	m.mutex.Lock()
 	for _, task := range m.Tasks {
 		if task.Schedule != "" {
-			id, err := m.Cron.AddFunc(task.Schedule, m.cronTask(task))
-			if err != nil {
+			if err := m.schedule(task); err != nil {
+				m.mutex.Unlock()
 				return err
 			}
-			task.EntryID = id
+			if n := m.missedRuns(task, now); n > 0 {
+				missed[task] = n
+			}
 		}
 	}
 	m.Cron.Start()
 	m.running = true
+	m.stopPrune = make(chan struct{})
+	m.mutex.Unlock()
+	// missed runs are dispatched without the lock
+	for task, n := range missed {
+		go m.catchUp(task, n)
+	}
+	go func() {
+		m.CompressLogs()
+		m.PruneLogs()
+	}()
+	go m.pruneLogsPeriodically(m.stopPrune)
 	return nil
 }
 
 // Stop stop's tasks scheduler
 func (m *TaskManager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.Cron.Stop()
+	if m.running {
+		close(m.stopPrune)
+	}
 	m.running = false
 }
@@ -0,0 +1,112 @@
+package dcron
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/robfig/cron/v3"
+)
+
+func newTestTaskManager() *TaskManager {
+	return &TaskManager{Cron: cron.New(), Tasks: make(map[string]*Task), redactor: &redactor{}}
+}
+
+func testConfig(tags ...string) TasksConfig {
+	task := execTask{Service: "db"}
+	task.Schedule = "@daily"
+	task.Tags = tags
+	unchanged := execTask{Service: "web"}
+	return TasksConfig{Exec: map[string]execTask{"backup": task, "cleanup": unchanged}}
+}
+
+func TestLoadConfigReplacesChangedTasks(t *testing.T) {
+	m := newTestTaskManager()
+	if _, err := m.LoadConfig(testConfig("db")); err != nil {
+		t.Fatal(err)
+	}
+	backup, _ := m.GetTask("backup")
+	cleanup, _ := m.GetTask("cleanup")
+
+	diff, err := m.LoadConfig(testConfig("db", "nightly"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0] != "backup" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	if task, _ := m.GetTask("backup"); task == backup || len(task.Tags) != 2 {
+		t.Errorf("changed task is not replaced: %+v", task)
+	}
+	if len(backup.Tags) != 1 {
+		t.Errorf("previous definition of changed task was modified: %v", backup.Tags)
+	}
+	if task, _ := m.GetTask("cleanup"); task != cleanup {
+		t.Error("unchanged task is replaced")
+	}
+}
+
+func TestLoadConfigConcurrentReads(t *testing.T) {
+	m := newTestTaskManager()
+	m.LoadConfig(testConfig("a"))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, task := range m.TasksList() {
+				_ = len(task.Tags) + len(task.Owner) + len(task.Schedule)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		m.LoadConfig(testConfig(string(rune('a' + i%2))))
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestNetworkName(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks map[string]string
+		network  string
+		expected string
+	}{
+		{"default network", nil, "", "app_default"},
+		{"custom default network", map[string]string{"default": "shared"}, "", "shared"},
+		{"compose network", map[string]string{"backend": "app_backend"}, "backend", "app_backend"},
+		{"network mode", map[string]string{"default": "shared"}, "host", "host"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &TaskManager{ProjectName: "app", Compose: &ComposeProject{Name: "app", Networks: test.networks}}
+			if name := m.networkName(test.network); name != test.expected {
+				t.Errorf("networkName(%q) = %q, expected %q", test.network, name, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDuplicateNames(t *testing.T) {
+	m := newTestTaskManager()
+	if _, err := m.LoadConfig(testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	config.Run = map[string]runTask{"backup": {Image: "alpine"}}
+	if _, err := m.LoadConfig(config); err == nil || !strings.Contains(err.Error(), `task "backup" is defined in both run: and exec:`) {
+		t.Fatalf("expected duplicate name error, got %v", err)
+	}
+	// previous configuration is kept
+	if task, ok := m.GetTask("backup"); !ok || !reflect.DeepEqual(task.config, testConfig().Exec["backup"]) {
+		t.Errorf("task was replaced by invalid configuration: %+v", task)
+	}
+}
@@ -40,6 +40,7 @@ new Vue({
       Vue.prototype.$ws = WebsocketConnection(`${location.protocol === 'https:' ? 'wss' : 'ws'}://${location.host}/ws`)
       this.$once('hook:beforeDestroy', this.$ws.bind('TaskStarted', this.onTaskStatusUpdated))
       this.$once('hook:beforeDestroy', this.$ws.bind('TaskFinished', this.onTaskStatusUpdated))
+      this.$once('hook:beforeDestroy', this.$ws.bind('ConfigReloaded', this.fetchTasks))
     },
     async fetchTasks () {
       const { data } = await this.$http.get('/api/tasks/')